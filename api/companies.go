@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Company is a production company or network as TMDB embeds it in movie and
+// TV details and returns it from /company/{id} and /network/{id}.
+type Company struct {
+	ID            uint32  `json:"id"`
+	Name          string  `json:"name"`
+	LogoPath      *string `json:"logo_path"`
+	OriginCountry string  `json:"origin_country"`
+}
+
+// DB structs
+
+type CinemaCompany struct {
+	ID            uint32     `json:"id" gorm:"primaryKey;autoIncrement:false"`
+	Name          string     `json:"name"`
+	LogoPath      *string    `json:"logo_path" gorm:"column:logoPath"`
+	OriginCountry *string    `json:"origin_country" gorm:"column:originCountry"`
+	SyncedAt      *time.Time `json:"-" gorm:"column:syncedAt;index"`
+}
+
+type MovieCompany struct {
+	MovieId   uint32 `gorm:"primaryKey;autoIncrement:false;column:movieId"`
+	CompanyId uint32 `gorm:"primaryKey;autoIncrement:false;column:companyId;index"`
+}
+
+type TVShowCompany struct {
+	ShowId    uint32 `gorm:"primaryKey;autoIncrement:false;column:showId"`
+	CompanyId uint32 `gorm:"primaryKey;autoIncrement:false;column:companyId;index"`
+}
+
+const (
+	// Networks and companies are fetched again once their last fetch is
+	// older than this, at most companyRefreshLimit of each per run.
+	companyRefreshInterval = 30 * 24 * time.Hour
+	companyRefreshLimit    = 2000
+)
+
+var (
+	companiesLimiter = rate.NewLimiter(rate.Every(time.Second/40), 1)
+)
+
+// Companies refreshes the TV networks and production companies that were
+// never fetched or are stale. The movie and TV syncs only insert them with
+// the fields embedded in the details.
+func Companies(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeSync) {
+		return
+	}
+	run, err := updateCompanies()
+	if err != nil {
+		var running *syncRunningError
+		if errors.As(err, &running) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error updating companies DB", http.StatusInternalServerError)
+		return
+	}
+	if run.Partial {
+		fmt.Fprintf(w, "Partially updated companies DB, resume from %s", *run.ResumeFrom)
+		return
+	}
+	fmt.Fprintf(w, "Finished updating companies DB")
+}
+
+func loadCinemaCompanies(db *gorm.DB, joinTable string, ownerColumn string, ownerId uint32) ([]CinemaCompany, error) {
+	companies := []CinemaCompany{}
+	err := db.Table("CinemaCompany").
+		Where(`id IN (SELECT "companyId" FROM "`+joinTable+`" WHERE "`+ownerColumn+`" = ?)`, ownerId).
+		Order("id").
+		Find(&companies).Error
+	return companies, err
+}
+
+func companyRef(company Company) CinemaCompany {
+	return CinemaCompany{
+		ID:            company.ID,
+		Name:          company.Name,
+		LogoPath:      company.LogoPath,
+		OriginCountry: filterEmptyString(company.OriginCountry),
+	}
+}
+
+func networkRef(company Company) Network {
+	return Network{
+		ID:            company.ID,
+		Name:          company.Name,
+		LogoPath:      company.LogoPath,
+		OriginCountry: filterEmptyString(company.OriginCountry),
+	}
+}
+
+// fetchCompanyData fetches a network or company. The caller waits on
+// companiesLimiter, see fetchSyncIds.
+func fetchCompanyData(entity string, id uint32) ([]byte, error) {
+	url := fmt.Sprintf("https://api.themoviedb.org/3/%s/%d", entity, id)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("API_ACCESS_TOKEN"))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status code: %d", res.StatusCode)
+	}
+	return io.ReadAll(res.Body)
+}
+
+func staleCompanyIds(db *gorm.DB, table string) []uint32 {
+	var ids []uint32
+	err := db.Table(table).
+		Where(`"syncedAt" IS NULL OR "syncedAt" < ?`, time.Now().Add(-companyRefreshInterval)).
+		Order(`"syncedAt" NULLS FIRST, id`).
+		Limit(companyRefreshLimit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		fmt.Printf("Error reading stale %s rows: %v\n", table, err)
+	}
+	return ids
+}
+
+// refreshCompanies fetches every stale row of table from the TMDB entity
+// endpoint and returns how many were left for the next run.
+func refreshCompanies(db *gorm.DB, run *SyncRun, stats *syncStats, entity string, table string) int {
+	idsCh := make(chan uint32, companyRefreshLimit)
+	for _, id := range staleCompanyIds(db, table) {
+		idsCh <- id
+	}
+	close(idsCh)
+	pendingIds := fetchSyncIds(run, companiesLimiter, idsCh, func(id uint32) {
+		refreshCompany(db, stats, entity, table, id)
+	})
+	return len(pendingIds)
+}
+
+func refreshCompany(db *gorm.DB, stats *syncStats, entity string, table string, id uint32) {
+	syncedAt := time.Now()
+	body, err := fetchCompanyData(entity, id)
+	if errors.Is(err, errNotFound) {
+		// Shows and movies still reference the row, so it is kept as is.
+		if err := db.Table(table).Where("id = ?", id).Update("syncedAt", syncedAt).Error; err != nil {
+			fmt.Printf("Error marking %s %d as synced: %v\n", entity, id, err)
+		}
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error fetching %s %d: %v\n", entity, id, err)
+		return
+	}
+	var company Company
+	if err := decodeIngest(body, &company, fmt.Sprintf("%s %d", entity, id), stats); err != nil {
+		fmt.Printf("Error parsing JSON data for %s %d: %v\n", entity, id, err)
+		return
+	}
+
+	err = db.Table(table).Where("id = ?", id).Updates(map[string]interface{}{
+		"name":          company.Name,
+		"logoPath":      company.LogoPath,
+		"originCountry": filterEmptyString(company.OriginCountry),
+		"syncedAt":      syncedAt,
+	}).Error
+	if err != nil {
+		fmt.Printf("Error writing %s %d: %v\n", entity, id, err)
+	}
+}
+
+func updateCompanies() (*SyncRun, error) {
+	fmt.Printf("Started updating companies at %s \n", time.Now().Format("15:04:05"))
+	db, err := openDB()
+	if err != nil {
+		fmt.Println("Error connecting to the DB:", err)
+		return nil, err
+	}
+	run, err := startSyncRun(db, "companies")
+	if err != nil {
+		return nil, err
+	}
+	var stats syncStats
+
+	// Rows left over stay stale and are picked again by the next run, so the
+	// checkpoint of a partial run is empty.
+	remaining := refreshCompanies(db, run, &stats, "network", "TVNetwork")
+	remaining += refreshCompanies(db, run, &stats, "company", "CinemaCompany")
+	if remaining > 0 {
+		run.markPartial(&SyncCheckpoint{}, fmt.Sprintf("%d stale networks and companies", remaining))
+	}
+	finishSyncRun(db, run, &stats)
+
+	fmt.Println("Successfully fetched data and written to the DB")
+	return run, nil
+}
+
+func writeCompanyRefRows(db *gorm.DB, dataChannel chan CinemaCompany, batchSize int, stats *syncStats) {
+	var batch []CinemaCompany
+	for entry := range dataChannel {
+		batch = append(batch, entry)
+		if len(batch) >= batchSize {
+			if err := writeCompanyRefsBatch(db, batch); err != nil {
+				fmt.Println("Error writing batch:", err)
+				stats.markRefsFailed()
+			}
+			batch = []CinemaCompany{}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := writeCompanyRefsBatch(db, batch); err != nil {
+			fmt.Println("Error writing final batch:", err)
+			stats.markRefsFailed()
+		}
+	}
+}
+func writeCompanyRefsBatch(db *gorm.DB, objects []CinemaCompany) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{DoNothing: true}).Table("CinemaCompany").Model(&CinemaCompany{}).Create(&objects).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ContentFilter lists what a sync kind leaves out. IGDB fields apply to the
+// games sync and the game ID scan, TMDB fields to the movies and TV syncs.
+type ContentFilter struct {
+	Kind              string         `json:"kind" gorm:"primaryKey"`
+	ExcludeThemes     pq.Int32Array  `json:"exclude_themes" gorm:"type:integer[];column:excludeThemes"`
+	ExcludeGenres     pq.Int32Array  `json:"exclude_genres" gorm:"type:integer[];column:excludeGenres"`
+	ExcludeCategories pq.Int32Array  `json:"exclude_categories" gorm:"type:integer[];column:excludeCategories"`
+	ExcludePlatforms  pq.Int32Array  `json:"exclude_platforms" gorm:"type:integer[];column:excludePlatforms"`
+	MinHypes          uint32         `json:"min_hypes" gorm:"column:minHypes"`
+	ExcludeAdult      bool           `json:"exclude_adult" gorm:"column:excludeAdult"`
+	ExcludeLanguages  pq.StringArray `json:"exclude_languages" gorm:"type:text[];column:excludeLanguages"`
+	MinPopularity     float32        `json:"min_popularity" gorm:"column:minPopularity"`
+	UpdatedAt         time.Time      `json:"updated_at" gorm:"column:updatedAt"`
+}
+
+var (
+	defaultContentFilters = map[string]ContentFilter{
+		"games":  {Kind: "games", ExcludeThemes: pq.Int32Array{42}},
+		"movies": {Kind: "movies", ExcludeAdult: true},
+		"tv":     {Kind: "tv", ExcludeAdult: true},
+	}
+)
+
+// ContentFilters lists the filter of every sync kind with GET and replaces the
+// filter of one kind with PUT ?kind= and a JSON body.
+func ContentFilters(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeSync) {
+		return
+	}
+	db, err := openDB()
+	if err != nil {
+		http.Error(w, "Error connecting to the DB", http.StatusInternalServerError)
+		return
+	}
+	if err := db.Table("ContentFilter").AutoMigrate(&ContentFilter{}); err != nil {
+		http.Error(w, "Error migrating content filters", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		filters := make([]ContentFilter, 0, len(defaultContentFilters))
+		for _, kind := range []string{"games", "movies", "tv"} {
+			filters = append(filters, loadContentFilter(db, kind))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(filters)
+	case http.MethodPut:
+		kind := r.URL.Query().Get("kind")
+		if _, ok := defaultContentFilters[kind]; !ok {
+			http.Error(w, "kind must be one of games, movies or tv", http.StatusBadRequest)
+			return
+		}
+		var filter ContentFilter
+		if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		filter.Kind = kind
+		filter.UpdatedAt = time.Now()
+		if err := db.Table("ContentFilter").Clauses(clause.OnConflict{UpdateAll: true}).Create(&filter).Error; err != nil {
+			http.Error(w, "Error saving content filter", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "Saved %s content filter", kind)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// loadContentFilter returns the stored filter of a sync kind, or its default
+// when none was saved.
+func loadContentFilter(db *gorm.DB, kind string) ContentFilter {
+	var filters []ContentFilter
+	if err := db.Table("ContentFilter").Where("kind = ?", kind).Limit(1).Find(&filters).Error; err != nil {
+		fmt.Println("Error reading content filter:", err)
+	}
+	if len(filters) == 0 {
+		return defaultContentFilters[kind]
+	}
+	return filters[0]
+}
+
+// gameReason returns why a game is filtered out, or "" when it is kept.
+func (filter ContentFilter) gameReason(game Game) string {
+	for _, theme := range game.Themes {
+		if slices.Contains(filter.ExcludeThemes, int32(theme)) {
+			return fmt.Sprintf("theme %d", theme)
+		}
+	}
+	for _, genre := range game.Genres {
+		if slices.Contains(filter.ExcludeGenres, int32(genre)) {
+			return fmt.Sprintf("genre %d", genre)
+		}
+	}
+	if slices.Contains(filter.ExcludeCategories, int32(game.Category)) {
+		return fmt.Sprintf("category %d", game.Category)
+	}
+	for _, platform := range game.Platforms {
+		if slices.Contains(filter.ExcludePlatforms, int32(platform)) {
+			return fmt.Sprintf("platform %d", platform)
+		}
+	}
+	if filter.MinHypes > 0 && (game.Hypes == nil || *game.Hypes < filter.MinHypes) {
+		return "hypes"
+	}
+	return ""
+}
+
+// mediaReason returns why a movie or TV show is filtered out by its details,
+// or "" when it is kept. The adult flag is checked on the change feed.
+func (filter ContentFilter) mediaReason(language *string, popularity float32) string {
+	if language != nil && slices.Contains(filter.ExcludeLanguages, *language) {
+		return "language " + *language
+	}
+	if popularity < filter.MinPopularity {
+		return "popularity"
+	}
+	return ""
+}
+
+func (stats *syncStats) markFiltered(id uint32, reason string) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	if stats.filteredIds == nil {
+		stats.filteredIds = map[string][]uint32{}
+	}
+	stats.filteredIds[reason] = append(stats.filteredIds[reason], id)
+}
+
+func (stats *syncStats) filteredCount() uint32 {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	var count uint32
+	for _, ids := range stats.filteredIds {
+		count += uint32(len(ids))
+	}
+	return count
+}
+
+func (stats *syncStats) filteredSummary() string {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	reasons := make([]string, 0, len(stats.filteredIds))
+	for reason := range stats.filteredIds {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	var summary strings.Builder
+	for _, reason := range reasons {
+		fmt.Fprintf(&summary, "Filtered by %s: %v\n", reason, stats.filteredIds[reason])
+	}
+	return summary.String()
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"gorm.io/gorm"
+)
+
+const gameIdScanPageSize = 500
+
+// GameIdScan walks every IGDB game ID in order and tombstones the games in the
+// DB that IGDB no longer returns. Games the content filter excludes are only
+// counted as filtered, like in the regular sync, so relaxing the filter brings
+// them back. It is meant to run periodically, apart from the regular games
+// sync, and resumes from its checkpoint when partial.
+func GameIdScan(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeSync) {
+		return
+	}
+	run, err := scanGameIds()
+	if err != nil {
+		var running *syncRunningError
+		if errors.As(err, &running) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error scanning game IDs", http.StatusInternalServerError)
+		return
+	}
+	if run.Partial {
+		fmt.Fprintf(w, "Partially scanned game IDs, %d deleted, resume from %s", run.Deleted, *run.ResumeFrom)
+		return
+	}
+	fmt.Fprintf(w, "Finished scanning game IDs, %d deleted", run.Deleted)
+}
+
+func fetchGameIdsData(afterId uint32) ([]byte, error) {
+	if err := limiter.Wait(context.Background()); err != nil {
+		fmt.Printf("Rate limit exceeded for IDs after %d: %v\n", afterId, err)
+	}
+
+	reqBody := []byte(fmt.Sprintf(`fields id, themes, genres, category, platforms, hypes; where id > %d; limit %d; sort id asc;`, afterId, gameIdScanPageSize))
+	req, err := http.NewRequest("POST", "https://api.igdb.com/v4/games", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Client-ID", os.Getenv("TWITCH_CLIENT_ID"))
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("TWITCH_TOKEN"))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status code: %d", res.StatusCode)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// missingGameIds returns the IDs of live games in (afterId, lastId] that are
+// not in the IGDB page. A lastId of 0 means the scan reached the end.
+func missingGameIds(db *gorm.DB, afterId uint32, lastId uint32, upstream map[uint32]bool) ([]uint32, error) {
+	var ids []uint32
+	query := db.Table("Game").Where(`id > ? AND "deletedAt" IS NULL`, afterId)
+	if lastId > 0 {
+		query = query.Where("id <= ?", lastId)
+	}
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	var missing []uint32
+	for _, id := range ids {
+		if !upstream[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+func scanGameIds() (*SyncRun, error) {
+	db, err := openDB()
+	if err != nil {
+		fmt.Println("Error connecting to the DB:", err)
+		return nil, err
+	}
+	run, err := startSyncRun(db, "game-ids")
+	if err != nil {
+		return nil, err
+	}
+	var stats syncStats
+
+	filter := loadContentFilter(db, "games")
+	checkpoint := loadSyncCheckpoint(db, "game-ids")
+	afterId := uint32(checkpoint.AfterId)
+	for {
+		if run.pastDeadline() {
+			run.markPartial(&SyncCheckpoint{AfterId: uint64(afterId)}, fmt.Sprintf("ID %d", afterId))
+			break
+		}
+		body, err := fetchGameIdsData(afterId)
+		if err != nil {
+			// Without a complete page, missing IDs cannot be told apart from a failed fetch.
+			fmt.Printf("Error fetching game IDs after %d: %v\n", afterId, err)
+			run.markPartial(&SyncCheckpoint{AfterId: uint64(afterId)}, fmt.Sprintf("ID %d", afterId))
+			break
+		}
+		var entries []Game
+		if err := decodeIngest(body, &entries, fmt.Sprintf("game IDs after %d", afterId), &stats); err != nil {
+			fmt.Printf("Error parsing game IDs after %d: %v\n", afterId, err)
+			run.markPartial(&SyncCheckpoint{AfterId: uint64(afterId)}, fmt.Sprintf("ID %d", afterId))
+			break
+		}
+
+		if afterId == 0 && len(entries) == 0 {
+			fmt.Println("Error scanning game IDs: IGDB returned no games")
+			break
+		}
+
+		upstream := make(map[uint32]bool, len(entries))
+		var lastId uint32
+		for _, entry := range entries {
+			lastId = max(lastId, entry.ID)
+			if reason := filter.gameReason(entry); reason != "" {
+				stats.markFiltered(entry.ID, reason)
+			}
+			upstream[entry.ID] = true
+		}
+		if len(entries) < gameIdScanPageSize {
+			lastId = 0
+		}
+		missing, err := missingGameIds(db, afterId, lastId, upstream)
+		if err != nil {
+			fmt.Println("Error reading game IDs:", err)
+			run.markPartial(&SyncCheckpoint{AfterId: uint64(afterId)}, fmt.Sprintf("ID %d", afterId))
+			break
+		}
+		stats.deletedIds = append(stats.deletedIds, missing...)
+		if lastId == 0 {
+			break
+		}
+		afterId = lastId
+	}
+
+	run.Deleted = tombstoneEntities(db, "Game", "game", stats.deletedIds)
+	finishSyncRun(db, run, &stats)
+	return run, nil
+}
@@ -0,0 +1,378 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type GameDetail struct {
+	ID                    uint32             `json:"id"`
+	Name                  string             `json:"name"`
+	Slug                  string             `json:"slug"`
+	AggregatedRating      float32            `json:"aggregated_rating"`
+	AggregatedRatingCount uint32             `json:"aggregated_rating_count"`
+	Category              uint8              `json:"category"`
+	FirstReleaseDate      *uint32            `json:"first_release_date"`
+	Follows               *uint32            `json:"follows"`
+	Hypes                 *uint32            `json:"hypes"`
+	Status                *uint8             `json:"status"`
+	Summary               *string            `json:"summary"`
+	VersionTitle          *string            `json:"version_title"`
+	Checksum              string             `json:"checksum"`
+	Cover                 *Cover             `json:"cover"`
+	AgeRatings            []AgeRating        `json:"age_ratings"`
+	AlternativeNames      []AlternativeName  `json:"alternative_names"`
+	Collection            *Collection        `json:"collection"`
+	Collections           []Collection       `json:"collections"`
+	Franchise             *Franchise         `json:"franchise"`
+	Franchises            []Franchise        `json:"franchises"`
+	GameEngines           []Engine           `json:"game_engines"`
+	GameLocalizations     []GameLocalization `json:"game_localizations"`
+	GameModes             []uint16           `json:"game_modes"`
+	Genres                []uint16           `json:"genres"`
+	LanguageSupports      []LanguageSupport  `json:"language_supports"`
+	Platforms             []uint16           `json:"platforms"`
+	PlayerPerspectives    []uint16           `json:"player_perspectives"`
+	ReleaseDates          []ReleaseDate      `json:"release_dates"`
+	Screenshots           []Screenshot       `json:"screenshots"`
+	Themes                []uint16           `json:"themes"`
+	Videos                []Video            `json:"videos"`
+	Websites              []Website          `json:"websites"`
+}
+
+// GameDetails returns a single game looked up by id or slug, assembled from
+// the tables the games sync writes. JSON field names follow the IGDB ones.
+func GameDetails(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeRead) {
+		return
+	}
+	db, err := openDB()
+	if err != nil {
+		http.Error(w, "Error connecting to the DB", http.StatusInternalServerError)
+		return
+	}
+
+	var base []GameBase
+	query := db.Table("Game").Where(`"deletedAt" IS NULL`).Limit(1)
+	if id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32); err == nil {
+		query = query.Where("id = ?", id)
+	} else if slug := r.URL.Query().Get("slug"); slug != "" {
+		query = query.Where("slug = ?", slug)
+	} else {
+		http.Error(w, "Missing id or slug", http.StatusBadRequest)
+		return
+	}
+	if err := query.Find(&base).Error; err != nil {
+		http.Error(w, "Error reading game", http.StatusInternalServerError)
+		return
+	}
+	if len(base) == 0 {
+		http.Error(w, "Game not found", http.StatusNotFound)
+		return
+	}
+
+	detail, err := loadGameDetail(db, base[0])
+	if err != nil {
+		http.Error(w, "Error reading game relations", http.StatusInternalServerError)
+		return
+	}
+	writeCachedJSON(w, r, detail)
+}
+
+func unixTime(input time.Time) uint32 {
+	if input.IsZero() {
+		return 0
+	}
+	return uint32(input.Unix())
+}
+
+func unixTimePtr(input *time.Time) *uint32 {
+	if input == nil {
+		return nil
+	}
+	result := unixTime(*input)
+	return &result
+}
+
+func loadGameDetail(db *gorm.DB, game GameBase) (GameDetail, error) {
+	detail := GameDetail{
+		ID:                    game.ID,
+		Name:                  game.Name,
+		Slug:                  game.Slug,
+		AggregatedRating:      game.AggregatedRating,
+		AggregatedRatingCount: game.AggregatedRatingCount,
+		Category:              game.Category,
+		FirstReleaseDate:      unixTimePtr(game.FirstReleaseDate),
+		Follows:               game.Follows,
+		Hypes:                 game.Hypes,
+		Status:                game.Status,
+		Summary:               game.Summary,
+		VersionTitle:          game.VersionTitle,
+		Checksum:              game.Checksum,
+		AgeRatings:            []AgeRating{},
+		AlternativeNames:      []AlternativeName{},
+		Collections:           []Collection{},
+		Franchises:            []Franchise{},
+		GameEngines:           []Engine{},
+		GameLocalizations:     []GameLocalization{},
+		GameModes:             []uint16{},
+		Genres:                []uint16{},
+		LanguageSupports:      []LanguageSupport{},
+		Platforms:             []uint16{},
+		PlayerPerspectives:    []uint16{},
+		ReleaseDates:          []ReleaseDate{},
+		Screenshots:           []Screenshot{},
+		Themes:                []uint16{},
+		Videos:                []Video{},
+		Websites:              []Website{},
+	}
+	byGame := func(table string) *gorm.DB {
+		return db.Table(table).Where(`"gameId" = ?`, game.ID)
+	}
+
+	var covers []CoverDB
+	if err := byGame("GCover").Limit(1).Find(&covers).Error; err != nil {
+		return detail, err
+	}
+	for _, cover := range covers {
+		detail.Cover = &Cover{
+			ID:           cover.ID,
+			AlphaChannel: cover.AlphaChannel,
+			Animated:     cover.Animated,
+			ImageID:      cover.ImageID,
+			Width:        cover.Width,
+			Height:       cover.Height,
+			Checksum:     cover.Checksum,
+		}
+	}
+
+	var ageRatings []AgeRatingDB
+	if err := byGame("GAgeRating").Order("id").Find(&ageRatings).Error; err != nil {
+		return detail, err
+	}
+	ageRatingIds := make([]uint32, 0, len(ageRatings))
+	for _, ageRating := range ageRatings {
+		ageRatingIds = append(ageRatingIds, ageRating.ID)
+	}
+	var contentDescs []ContentDescriptionDB
+	if len(ageRatingIds) > 0 {
+		if err := db.Table("GAgeRatingDescription").Where(`"ageRatingId" IN ?`, ageRatingIds).Order("id").Find(&contentDescs).Error; err != nil {
+			return detail, err
+		}
+	}
+	contentDescsByRating := map[uint32][]ContentDescriptionDB{}
+	for _, contentDesc := range contentDescs {
+		contentDescsByRating[contentDesc.AgeRatingId] = append(contentDescsByRating[contentDesc.AgeRatingId], contentDesc)
+	}
+	for _, ageRating := range ageRatings {
+		rating := AgeRating{
+			ID:                  ageRating.ID,
+			Category:            ageRating.Category,
+			Rating:              ageRating.Rating,
+			RatingCoverUrl:      ageRating.RatingCoverUrl,
+			Synopsis:            ageRating.Synopsis,
+			Checksum:            ageRating.Checksum,
+			ContentDescriptions: []ContentDescription{},
+		}
+		for _, contentDesc := range contentDescsByRating[ageRating.ID] {
+			rating.ContentDescriptions = append(rating.ContentDescriptions, ContentDescription{
+				ID:          contentDesc.ID,
+				Category:    contentDesc.Category,
+				Description: contentDesc.Description,
+				Checksum:    contentDesc.Checksum,
+			})
+		}
+		detail.AgeRatings = append(detail.AgeRatings, rating)
+	}
+
+	var altNames []AltNameDB
+	if err := byGame("GAltName").Order("id").Find(&altNames).Error; err != nil {
+		return detail, err
+	}
+	for _, altName := range altNames {
+		detail.AlternativeNames = append(detail.AlternativeNames, AlternativeName{
+			ID:       altName.ID,
+			Name:     altName.Name,
+			Comment:  altName.Comment,
+			Checksum: altName.Checksum,
+		})
+	}
+
+	var localizations []LocalizationDB
+	if err := byGame("GLocalization").Order("id").Find(&localizations).Error; err != nil {
+		return detail, err
+	}
+	for _, localization := range localizations {
+		detail.GameLocalizations = append(detail.GameLocalizations, GameLocalization{
+			ID:        localization.ID,
+			Name:      localization.Name,
+			Region:    localization.RegionId,
+			UpdatedAt: unixTime(localization.UpdatedAt),
+			Checksum:  localization.Checksum,
+		})
+	}
+
+	var langSupps []LanguageSupportDB
+	if err := byGame("GLanguageSupport").Order("id").Find(&langSupps).Error; err != nil {
+		return detail, err
+	}
+	for _, langSupp := range langSupps {
+		detail.LanguageSupports = append(detail.LanguageSupports, LanguageSupport{
+			ID:                  langSupp.ID,
+			Language:            langSupp.LanguageId,
+			LanguageSupportType: langSupp.SupportTypeId,
+			UpdatedAt:           unixTime(langSupp.UpdatedAt),
+			Checksum:            langSupp.Checksum,
+		})
+	}
+
+	var releaseDates []ReleaseDateDB
+	if err := byGame("GReleaseDate").Order("date").Find(&releaseDates).Error; err != nil {
+		return detail, err
+	}
+	for _, releaseDate := range releaseDates {
+		detail.ReleaseDates = append(detail.ReleaseDates, ReleaseDate{
+			ID:        releaseDate.ID,
+			Category:  releaseDate.Category,
+			Date:      unixTimePtr(releaseDate.Date),
+			Human:     releaseDate.Human,
+			Month:     releaseDate.Month,
+			Year:      releaseDate.Year,
+			Status:    releaseDate.StatusId,
+			Platform:  releaseDate.PlatformId,
+			Region:    releaseDate.Region,
+			UpdatedAt: unixTime(releaseDate.UpdatedAt),
+			Checksum:  releaseDate.Checksum,
+		})
+	}
+
+	var screenshots []ScreenshotDB
+	if err := byGame("GScreenshot").Order("id").Find(&screenshots).Error; err != nil {
+		return detail, err
+	}
+	for _, screenshot := range screenshots {
+		detail.Screenshots = append(detail.Screenshots, Screenshot{
+			ID:           screenshot.ID,
+			AlphaChannel: screenshot.AlphaChannel,
+			Animated:     screenshot.Animated,
+			ImageID:      screenshot.ImageID,
+			Width:        screenshot.Width,
+			Height:       screenshot.Height,
+			Checksum:     screenshot.Checksum,
+		})
+	}
+
+	var videos []VideoDB
+	if err := byGame("GVideo").Order("id").Find(&videos).Error; err != nil {
+		return detail, err
+	}
+	for _, video := range videos {
+		detail.Videos = append(detail.Videos, Video{
+			ID:       video.ID,
+			Name:     video.Name,
+			VideoId:  video.VideoId,
+			Checksum: video.Checksum,
+		})
+	}
+
+	var websites []WebsiteDB
+	if err := byGame("GWebsite").Order("id").Find(&websites).Error; err != nil {
+		return detail, err
+	}
+	for _, website := range websites {
+		detail.Websites = append(detail.Websites, Website{
+			ID:       website.ID,
+			Category: website.Category,
+			Url:      website.Url,
+			Trusted:  website.Trusted,
+			Checksum: website.Checksum,
+		})
+	}
+
+	var collections []CollectionDB
+	err := db.Table("GCollection").
+		Where(`id = ? OR id IN (SELECT "collectionId" FROM "GameCollection" WHERE "gameId" = ?)`, game.MainSeriesId, game.ID).
+		Order("id").
+		Find(&collections).Error
+	if err != nil {
+		return detail, err
+	}
+	for _, collection := range collections {
+		item := Collection{
+			ID:        collection.ID,
+			Name:      collection.Name,
+			Slug:      collection.Slug,
+			TypeId:    collection.TypeId,
+			UpdatedAt: unixTime(collection.UpdatedAt),
+			Checksum:  collection.Checksum,
+		}
+		if game.MainSeriesId != nil && *game.MainSeriesId == collection.ID {
+			mainSeries := item
+			detail.Collection = &mainSeries
+		}
+		detail.Collections = append(detail.Collections, item)
+	}
+
+	var franchises []FranchiseDB
+	err = db.Table("GFranchise").
+		Where(`id = ? OR id IN (SELECT "franchiseId" FROM "GameFranchise" WHERE "gameId" = ?)`, game.MainFranchiseId, game.ID).
+		Order("id").
+		Find(&franchises).Error
+	if err != nil {
+		return detail, err
+	}
+	for _, franchise := range franchises {
+		item := Franchise{
+			ID:        franchise.ID,
+			Name:      franchise.Name,
+			Slug:      franchise.Slug,
+			UpdatedAt: unixTime(franchise.UpdatedAt),
+			Checksum:  franchise.Checksum,
+		}
+		if game.MainFranchiseId != nil && *game.MainFranchiseId == franchise.ID {
+			mainFranchise := item
+			detail.Franchise = &mainFranchise
+		}
+		detail.Franchises = append(detail.Franchises, item)
+	}
+
+	var engines []EngineDB
+	err = db.Table("GEngine").
+		Where(`id IN (SELECT "engineId" FROM "GameEngine" WHERE "gameId" = ?)`, game.ID).
+		Order("id").
+		Find(&engines).Error
+	if err != nil {
+		return detail, err
+	}
+	for _, engine := range engines {
+		detail.GameEngines = append(detail.GameEngines, Engine{
+			ID:          engine.ID,
+			Name:        engine.Name,
+			Slug:        engine.Slug,
+			Description: engine.Description,
+			UpdatedAt:   unixTime(engine.UpdatedAt),
+			Checksum:    engine.Checksum,
+		})
+	}
+
+	if err := byGame("GameMode").Order(`"modeId"`).Pluck(`"modeId"`, &detail.GameModes).Error; err != nil {
+		return detail, err
+	}
+	if err := byGame("GameGenre").Order(`"genreId"`).Pluck(`"genreId"`, &detail.Genres).Error; err != nil {
+		return detail, err
+	}
+	if err := byGame("GamePlayerPerspective").Order(`"perspectiveId"`).Pluck(`"perspectiveId"`, &detail.PlayerPerspectives).Error; err != nil {
+		return detail, err
+	}
+	if err := byGame("GamePlatform").Order(`"platformId"`).Pluck(`"platformId"`, &detail.Platforms).Error; err != nil {
+		return detail, err
+	}
+	if err := byGame("GameTheme").Order(`"themeId"`).Pluck(`"themeId"`, &detail.Themes).Error; err != nil {
+		return detail, err
+	}
+
+	return detail, nil
+}
@@ -3,7 +3,7 @@ package handler
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,7 +14,6 @@ import (
 	"github.com/lib/pq"
 	"golang.org/x/time/rate"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -210,8 +209,9 @@ type GameBase struct {
 	VersionTitle          *string   `gorm:"column:versionTitle"`
 	UpdatedAt             time.Time `gorm:"column:updatedAt"`
 	Checksum              string
-	MainSeriesId          *uint32 `gorm:"column:mainSeriesId"`
-	MainFranchiseId       *uint32 `gorm:"column:mainFranchiseId"`
+	MainSeriesId          *uint32    `gorm:"column:mainSeriesId"`
+	MainFranchiseId       *uint32    `gorm:"column:mainFranchiseId"`
+	DeletedAt             *time.Time `gorm:"column:deletedAt"`
 }
 
 type AgeRatingDB struct {
@@ -395,18 +395,66 @@ var (
 	limiter = rate.NewLimiter(rate.Every(time.Second/4), 1)
 )
 
+const gamesPageSize = 500
+
 func Games(w http.ResponseWriter, r *http.Request) {
-	updateGames()
+	if !authorize(w, r, scopeSync) {
+		return
+	}
+	run, err := updateGames()
+	if err != nil {
+		var running *syncRunningError
+		if errors.As(err, &running) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error updating games DB", http.StatusInternalServerError)
+		return
+	}
+	if run.Partial {
+		fmt.Fprintf(w, "Partially updated games DB, resume from %s", *run.ResumeFrom)
+		return
+	}
 	fmt.Fprintf(w, "Finished updating games DB")
 }
 
-func fetchData(pageNum uint8) ([]byte, error) {
+// gamesCursor is the last game of a page, the next page starts right after it.
+// The zero cursor starts at the most recently updated game.
+type gamesCursor struct {
+	UpdatedAt uint32
+	ID        uint32
+}
+
+// where returns the filter for the page after the cursor. Games are listed by
+// updated_at and ID, both descending, so games sharing an updated_at are split
+// between pages without being fetched twice or skipped.
+func (cursor gamesCursor) where() string {
+	if cursor == (gamesCursor{}) {
+		return ""
+	}
+	return fmt.Sprintf(" where updated_at < %d | (updated_at = %d & id < %d);", cursor.UpdatedAt, cursor.UpdatedAt, cursor.ID)
+}
+
+// nextGamesCursor returns the cursor after a page, the zero cursor once the
+// listing is exhausted.
+func nextGamesCursor(games []Game) gamesCursor {
+	if len(games) < gamesPageSize {
+		return gamesCursor{}
+	}
+	last := games[len(games)-1]
+	return gamesCursor{UpdatedAt: last.UpdatedAt, ID: last.ID}
+}
+
+// fetchData fetches the page of games after the cursor, most recently updated
+// first. Pages are keyed on updated_at and ID rather than an offset: games
+// updated while the sync runs move to the front of the listing and would shift
+// every offset.
+func fetchData(pageNum uint16, after gamesCursor) ([]byte, error) {
 	if err := limiter.Wait(context.Background()); err != nil {
 		fmt.Printf("Rate limit exceeded for Page %d: %v\n", pageNum, err)
 	}
 
-	offsetValue := uint32(pageNum-1) * 500
-	reqBodyString := fmt.Sprintf(`fields *, age_ratings.*, age_ratings.content_descriptions.*, alternative_names.*, cover.*, game_localizations.*, external_games.*, language_supports.*, release_dates.*, screenshots.*, videos.*, websites.*, collection.*, collections.*, franchise.*, franchises.*, game_engines.*;	where themes != (42); limit 500; offset %d; sort updated_at desc;`, offsetValue)
+	reqBodyString := fmt.Sprintf(`fields *, age_ratings.*, age_ratings.content_descriptions.*, alternative_names.*, cover.*, game_localizations.*, external_games.*, language_supports.*, release_dates.*, screenshots.*, videos.*, websites.*, collection.*, collections.*, franchise.*, franchises.*, game_engines.*;%s limit %d; sort updated_at desc; sort id desc;`, after.where(), gamesPageSize)
 	reqBody := []byte(reqBodyString)
 
 	url := "https://api.igdb.com/v4/games"
@@ -443,7 +491,12 @@ func convertToDate(input *uint32) *time.Time {
 	return result
 }
 
-func fetchAndProcessData(pageNum uint8,
+// fetchAndProcessData fetches the page of games after the cursor and returns
+// the cursor of the next page, the zero cursor once the listing is exhausted.
+func fetchAndProcessData(pageNum uint16,
+	after gamesCursor,
+	filter ContentFilter,
+	stats *syncStats,
 	gameBaseCh chan GameBase,
 	ageRatingCh chan AgeRatingDB,
 	contentDescCh chan ContentDescriptionDB,
@@ -467,20 +520,23 @@ func fetchAndProcessData(pageNum uint8,
 	gamePlayerPerspectiveCh chan GamePlayerPerspective,
 	gamePlatformCh chan GamePlatform,
 	gameThemeCh chan GameTheme,
-) {
-	body, err := fetchData(pageNum)
+) (gamesCursor, error) {
+	body, err := fetchData(pageNum, after)
 	if err != nil {
-		fmt.Printf("Error fetching details for Page %d: %v\n", pageNum, err)
-		return
+		return gamesCursor{}, err
 	}
 	var games []Game
-	err = json.Unmarshal(body, &games)
+	err = decodeIngest(body, &games, fmt.Sprintf("games page %d", pageNum), stats)
 	if err != nil {
-		fmt.Println("Error parsing JSON data for Page:", pageNum, err)
-		return
+		return gamesCursor{}, err
 	}
+	next := nextGamesCursor(games)
 
 	for _, game := range games {
+		if reason := filter.gameReason(game); reason != "" {
+			stats.markFiltered(game.ID, reason)
+			continue
+		}
 		var gameBase = GameBase{
 			ID:                    game.ID,
 			Name:                  game.Name,
@@ -733,22 +789,22 @@ func fetchAndProcessData(pageNum uint8,
 			}
 		}
 	}
+	return next, nil
 }
 
-func updateGames() {
+func updateGames() (*SyncRun, error) {
 	fmt.Printf("Started updating games at %s \n", time.Now().Format("15:04:05"))
 
-	username := os.Getenv("POSTGRES_USER")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	host := os.Getenv("POSTGRES_HOST")
-	port := os.Getenv("POSTGRES_PORT")
-	database := os.Getenv("POSTGRES_DATABASE")
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=require TimeZone=Asia/Shanghai",
-		host, username, password, database, port)
-	db, _ := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		PrepareStmt:            true,
-		SkipDefaultTransaction: true,
-	}, nil)
+	db, err := openDB()
+	if err != nil {
+		fmt.Println("Error connecting to the DB:", err)
+		return nil, err
+	}
+	run, err := startSyncRun(db, "games")
+	if err != nil {
+		return nil, err
+	}
+	var stats syncStats
 
 	const batchSize = 3000
 	const totalPages = 16
@@ -777,41 +833,60 @@ func updateGames() {
 	gamePlatformCh := make(chan GamePlatform, 100000)
 	gameThemeCh := make(chan GameTheme, 100000)
 
+	filter := loadContentFilter(db, "games")
+	startPage := uint16(1)
+	var after gamesCursor
+	if checkpoint := loadSyncCheckpoint(db, "games"); checkpoint.Page > 1 && checkpoint.Page <= totalPages && checkpoint.BeforeId > 0 {
+		startPage = checkpoint.Page
+		after = gamesCursor{UpdatedAt: checkpoint.BeforeUpdatedAt, ID: checkpoint.BeforeId}
+		fmt.Printf("Resuming games sync from page %d\n", startPage)
+	}
+
 	go func() {
-		var wgFetch sync.WaitGroup
-		var i uint8
-		for i = 1; i <= totalPages; i++ {
-			wgFetch.Add(1)
-			go func(i uint8) {
-				defer wgFetch.Done()
-				pageNum := i
-				fetchAndProcessData(pageNum,
-					gameBaseCh,
-					ageRatingCh,
-					contentDescCh,
-					altNameCh,
-					coverCh,
-					localizationCh,
-					externalServiceCh,
-					languageSupportCh,
-					releaseDateCh,
-					screenshotCh,
-					videoCh,
-					websiteCh,
-					collectionCh,
-					franchiseCh,
-					engineCh,
-					gameCollectionCh,
-					gameFranchiseCh,
-					gameEngineCh,
-					gameModeCh,
-					gameGenreCh,
-					gamePlayerPerspectiveCh,
-					gamePlatformCh,
-					gameThemeCh)
-			}(i)
-		}
-		wgFetch.Wait()
+		// Each page starts where the previous one ended, so pages are fetched
+		// one after another.
+		for pageNum := startPage; pageNum <= totalPages; pageNum++ {
+			if run.pastDeadline() {
+				run.markPartial(&SyncCheckpoint{Page: pageNum, BeforeUpdatedAt: after.UpdatedAt, BeforeId: after.ID}, fmt.Sprintf("page %d", pageNum))
+				break
+			}
+			next, err := fetchAndProcessData(pageNum,
+				after,
+				filter,
+				&stats,
+				gameBaseCh,
+				ageRatingCh,
+				contentDescCh,
+				altNameCh,
+				coverCh,
+				localizationCh,
+				externalServiceCh,
+				languageSupportCh,
+				releaseDateCh,
+				screenshotCh,
+				videoCh,
+				websiteCh,
+				collectionCh,
+				franchiseCh,
+				engineCh,
+				gameCollectionCh,
+				gameFranchiseCh,
+				gameEngineCh,
+				gameModeCh,
+				gameGenreCh,
+				gamePlayerPerspectiveCh,
+				gamePlatformCh,
+				gameThemeCh)
+			if err != nil {
+				fmt.Printf("Error fetching games page %d: %v\n", pageNum, err)
+				run.markPartial(&SyncCheckpoint{Page: pageNum, BeforeUpdatedAt: after.UpdatedAt, BeforeId: after.ID}, fmt.Sprintf("page %d", pageNum))
+				break
+			}
+			if next == (gamesCursor{}) {
+				break
+			}
+			after = next
+		}
 		close(gameBaseCh)
 		close(ageRatingCh)
 		close(contentDescCh)
@@ -850,7 +925,7 @@ func updateGames() {
 	wgWriteBase.Add(1)
 	go func() {
 		defer wgWriteBase.Done()
-		writeBaseRows(db, gameBaseCh, batchSize)
+		writeBaseRows(db, gameBaseCh, batchSize, &stats)
 	}()
 	wgWriteBase.Wait()
 
@@ -892,17 +967,22 @@ func updateGames() {
 		defer wgWriteChildSecond.Done()
 		writeContentDescRows(db, contentDescCh, batchSize)
 	}()
+	wgWriteChildSecond.Wait()
+
+	finishSyncRun(db, run, &stats)
 
 	fmt.Println("Successfully fetched data and written to the DB")
+	return run, nil
 }
 
-func writeBaseRows(db *gorm.DB, dataChannel chan GameBase, batchSize int) {
+func writeBaseRows(db *gorm.DB, dataChannel chan GameBase, batchSize int, stats *syncStats) {
 	var batch []GameBase
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writeBasesBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				stats.markFailed(gameBaseIds(batch)...)
 			}
 			batch = []GameBase{}
 		}
@@ -911,11 +991,52 @@ func writeBaseRows(db *gorm.DB, dataChannel chan GameBase, batchSize int) {
 	if len(batch) > 0 {
 		if err := writeBasesBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			stats.markFailed(gameBaseIds(batch)...)
 		}
 	}
 }
+
+func gameBaseIds(objects []GameBase) []uint32 {
+	ids := make([]uint32, 0, len(objects))
+	for _, object := range objects {
+		ids = append(ids, object.ID)
+	}
+	return ids
+}
+
+// uniqueGameBases keeps the last row of each game, an upsert that touches the
+// same row twice fails as a whole.
+func uniqueGameBases(objects []GameBase) []GameBase {
+	positions := make(map[uint32]int, len(objects))
+	unique := make([]GameBase, 0, len(objects))
+	for _, object := range objects {
+		if i, ok := positions[object.ID]; ok {
+			unique[i] = object
+			continue
+		}
+		positions[object.ID] = len(unique)
+		unique = append(unique, object)
+	}
+	return unique
+}
+
 func writeBasesBatch(db *gorm.DB, objects []GameBase) error {
+	objects = uniqueGameBases(objects)
 	return db.Transaction(func(tx *gorm.DB) error {
+		ids := make([]uint32, 0, len(objects))
+		for _, object := range objects {
+			ids = append(ids, object.ID)
+		}
+		payloads, err := createdEntityPayloads(tx, "Game", "game", ids)
+		if err != nil {
+			return err
+		}
+		if err := enqueueWebhookEvents(tx, payloads); err != nil {
+			return err
+		}
+		if err := recordOutboxEvents(tx, "Game", "game", objects); err != nil {
+			return err
+		}
 		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{UpdateAll: true}).Table("Game").Model(&GameBase{}).Create(&objects).Error; err != nil {
 			return err
 		}
@@ -1131,6 +1252,9 @@ func writeReleaseDateRows(db *gorm.DB, dataChannel chan ReleaseDateDB, batchSize
 }
 func writeReleaseDatesBatch(db *gorm.DB, objects []ReleaseDateDB) error {
 	return db.Transaction(func(tx *gorm.DB) error {
+		if err := recordGameReleaseDateChanges(tx, objects); err != nil {
+			return err
+		}
 		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{UpdateAll: true}).Table("GReleaseDate").Model(&ReleaseDateDB{}).Create(&objects).Error; err != nil {
 			return err
 		}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestGamesCursorWhere(t *testing.T) {
+	if where := (gamesCursor{}).where(); where != "" {
+		t.Errorf("first page where = %q, want none", where)
+	}
+	want := " where updated_at < 1700000000 | (updated_at = 1700000000 & id < 1942);"
+	if where := (gamesCursor{UpdatedAt: 1700000000, ID: 1942}).where(); where != want {
+		t.Errorf("where = %q, want %q", where, want)
+	}
+}
+
+// TestGamesPaging pages through a listing the way IGDB answers the cursor
+// filter, with a run of games sharing one updated_at across a page boundary
+// and one longer than a whole page.
+func TestGamesPaging(t *testing.T) {
+	var listing []Game
+	id := uint32(1)
+	for _, run := range []struct {
+		updatedAt uint32
+		count     int
+	}{
+		{1700000300, gamesPageSize - 3},
+		{1700000200, 10},
+		{1700000100, gamesPageSize + 20},
+		{1700000000, 7},
+	} {
+		for i := 0; i < run.count; i++ {
+			listing = append(listing, Game{ID: id, UpdatedAt: run.updatedAt})
+			id++
+		}
+	}
+	sort.Slice(listing, func(i, j int) bool {
+		if listing[i].UpdatedAt != listing[j].UpdatedAt {
+			return listing[i].UpdatedAt > listing[j].UpdatedAt
+		}
+		return listing[i].ID > listing[j].ID
+	})
+	page := func(after gamesCursor) []Game {
+		var games []Game
+		for _, game := range listing {
+			if after != (gamesCursor{}) && (game.UpdatedAt > after.UpdatedAt || game.UpdatedAt == after.UpdatedAt && game.ID >= after.ID) {
+				continue
+			}
+			games = append(games, game)
+			if len(games) == gamesPageSize {
+				break
+			}
+		}
+		return games
+	}
+
+	seen := map[uint32]int{}
+	var after gamesCursor
+	for pages := 1; ; pages++ {
+		if pages > len(listing) {
+			t.Fatal("paging did not end")
+		}
+		games := page(after)
+		for _, game := range games {
+			seen[game.ID]++
+		}
+		after = nextGamesCursor(games)
+		if after == (gamesCursor{}) {
+			break
+		}
+	}
+	if len(seen) != len(listing) {
+		t.Errorf("fetched %d games, want %d", len(seen), len(listing))
+	}
+	for id, count := range seen {
+		if count != 1 {
+			t.Errorf("game %d fetched %d times", id, count)
+		}
+	}
+}
+
+func TestUniqueGameBases(t *testing.T) {
+	batch := []GameBase{{ID: 1, Name: "old"}, {ID: 2}, {ID: 1, Name: "new"}, {ID: 3}}
+	unique := uniqueGameBases(batch)
+	if len(unique) != 3 {
+		t.Fatalf("got %d rows, want 3", len(unique))
+	}
+	if unique[0].ID != 1 || unique[0].Name != "new" || unique[1].ID != 2 || unique[2].ID != 3 {
+		t.Errorf("got %+v, want games 1, 2 and 3 with the last row of game 1", unique)
+	}
+}
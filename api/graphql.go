@@ -0,0 +1,773 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+type relationLoader struct {
+	mu      sync.Mutex
+	pending map[uint32]bool
+	loaded  map[uint32][]interface{}
+	fetch   func(ids []uint32) (map[uint32][]interface{}, error)
+}
+
+// graphQLLoaders batch relation lookups per request. List resolvers prime
+// the loaders of an entity type with every parent ID, so the first child
+// resolver loads the relation for all parents in a single query.
+type graphQLLoaders struct {
+	db      *gorm.DB
+	loaders map[string]*relationLoader
+}
+
+type graphQLLoadersKey struct{}
+
+const (
+	maxGraphQLDepth      = 6
+	maxGraphQLComplexity = 5000
+	graphQLListCost      = 10
+	graphQLDefaultLimit  = 20
+	graphQLMaxLimit      = 100
+)
+
+// graphQLListFields are the root fields paged with listArgs.
+var graphQLListFields = map[string]bool{"games": true, "movies": true, "tvShows": true}
+
+var (
+	graphQLSchemaCache = &sync.Map{}
+	graphQLSchema      graphql.Schema
+	graphQLSchemaErr   error
+	graphQLSchemaOnce  sync.Once
+)
+
+// GraphQL serves queries over games, movies and TV shows and their relations,
+// via POST {query, variables, operationName} or GET ?query=. Queries deeper
+// than maxGraphQLDepth or costlier than maxGraphQLComplexity are rejected.
+func GraphQL(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeRead) {
+		return
+	}
+	var request GraphQLRequest
+	switch r.Method {
+	case http.MethodGet:
+		request.Query = r.URL.Query().Get("query")
+		request.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				http.Error(w, "Invalid variables", http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	graphQLSchemaOnce.Do(func() {
+		graphQLSchema, graphQLSchemaErr = buildGraphQLSchema()
+	})
+	if graphQLSchemaErr != nil {
+		http.Error(w, "Error building GraphQL schema", http.StatusInternalServerError)
+		return
+	}
+
+	result := executeGraphQL(r.Context(), request)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func executeGraphQL(ctx context.Context, request GraphQLRequest) *graphql.Result {
+	document, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(request.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: graphQLErrors(err)}
+	}
+	validation := graphql.ValidateDocument(&graphQLSchema, document, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+	if err := checkGraphQLLimits(document, request.Variables); err != nil {
+		return &graphql.Result{Errors: graphQLErrors(err)}
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return &graphql.Result{Errors: graphQLErrors(fmt.Errorf("error connecting to the DB"))}
+	}
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        graphQLSchema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       context.WithValue(ctx, graphQLLoadersKey{}, newGraphQLLoaders(db)),
+	})
+}
+
+func graphQLErrors(err error) []gqlerrors.FormattedError {
+	return []gqlerrors.FormattedError{gqlerrors.FormatError(err)}
+}
+
+// Limits
+
+func checkGraphQLLimits(document *ast.Document, variables map[string]interface{}) error {
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		depth, complexity := measureSelectionSet(operation.SelectionSet, fragments, variables, map[string]bool{})
+		if depth > maxGraphQLDepth {
+			return fmt.Errorf("query depth %d exceeds the limit of %d", depth, maxGraphQLDepth)
+		}
+		if complexity > maxGraphQLComplexity {
+			return fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, maxGraphQLComplexity)
+		}
+	}
+	return nil
+}
+
+// measureSelectionSet returns the nesting depth of a selection set and its
+// complexity: one point per field, with the cost of the fields below a list
+// multiplied by the number of rows the list can return: the effective limit
+// of a paged root field, or graphQLListCost for nested lists.
+func measureSelectionSet(selectionSet *ast.SelectionSet, fragments map[string]*ast.FragmentDefinition, variables map[string]interface{}, visiting map[string]bool) (int, int) {
+	if selectionSet == nil {
+		return 0, 0
+	}
+	maxDepth := 0
+	complexity := 0
+	for _, selection := range selectionSet.Selections {
+		var depth, cost int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			childDepth, childCost := measureSelectionSet(selection.SelectionSet, fragments, variables, visiting)
+			depth = childDepth + 1
+			cost = 1
+			if selection.SelectionSet != nil {
+				cost += childCost * graphQLFieldMultiplier(selection, variables)
+			}
+		case *ast.InlineFragment:
+			depth, cost = measureSelectionSet(selection.SelectionSet, fragments, variables, visiting)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := fragments[name]
+			if !ok || visiting[name] {
+				continue
+			}
+			visiting[name] = true
+			depth, cost = measureSelectionSet(fragment.SelectionSet, fragments, variables, visiting)
+			delete(visiting, name)
+		}
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		complexity += cost
+	}
+	return maxDepth, complexity
+}
+
+func graphQLFieldMultiplier(field *ast.Field, variables map[string]interface{}) int {
+	if graphQLListFields[field.Name.Value] {
+		limit := graphQLDefaultLimit
+		ids := -1
+		for _, argument := range field.Arguments {
+			switch argument.Name.Value {
+			case "limit":
+				switch value := argument.Value.(type) {
+				case *ast.IntValue:
+					fmt.Sscan(value.Value, &limit)
+				case *ast.Variable:
+					if variable, ok := variables[value.Name.Value].(float64); ok {
+						limit = int(variable)
+					}
+				}
+			case "ids":
+				switch value := argument.Value.(type) {
+				case *ast.ListValue:
+					ids = len(value.Values)
+				case *ast.Variable:
+					if variable, ok := variables[value.Name.Value].([]interface{}); ok {
+						ids = len(variable)
+					}
+				}
+			}
+		}
+		limit = graphQLEffectiveLimit(limit)
+		if ids >= 0 {
+			return max(min(ids, limit), 1)
+		}
+		return limit
+	}
+	if strings.HasSuffix(field.Name.Value, "s") || strings.HasSuffix(field.Name.Value, "Countries") {
+		return graphQLListCost
+	}
+	return 1
+}
+
+// graphQLEffectiveLimit clamps a requested limit the way listResolver does.
+func graphQLEffectiveLimit(limit int) int {
+	return min(max(limit, 1), graphQLMaxLimit)
+}
+
+// Loaders
+
+func newGraphQLLoaders(db *gorm.DB) *graphQLLoaders {
+	l := &graphQLLoaders{db: db, loaders: map[string]*relationLoader{}}
+
+	l.register("game.releaseDates", func(ids []uint32) (map[uint32][]interface{}, error) {
+		var rows []ReleaseDateDB
+		if err := db.Table("GReleaseDate").Where(`"gameId" IN ?`, ids).Order("date").Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		result := map[uint32][]interface{}{}
+		for _, row := range rows {
+			result[row.GameId] = append(result[row.GameId], row)
+		}
+		return result, nil
+	})
+	l.registerJoinIds("game.platforms", "GamePlatform", "gameId", "platformId")
+	l.registerJoinIds("game.genres", "GameGenre", "gameId", "genreId")
+	l.registerJoinIds("game.themes", "GameTheme", "gameId", "themeId")
+	l.registerJoinIds("game.gameModes", "GameMode", "gameId", "modeId")
+	l.registerJoinIds("game.playerPerspectives", "GamePlayerPerspective", "gameId", "perspectiveId")
+	l.registerJoinIds("game.collections", "GameCollection", "gameId", "collectionId")
+	l.registerJoinIds("game.franchises", "GameFranchise", "gameId", "franchiseId")
+	l.registerJoinIds("game.engines", "GameEngine", "gameId", "engineId")
+
+	l.registerPeople("movie.actors", "MovieActor", "movieId", "actorId")
+	l.registerPeople("movie.directors", "MovieDirector", "movieId", "directorId")
+	l.registerJoinIds("movie.genres", "MovieGenre", "movieId", "genreId")
+	l.registerJoinIds("movie.productionCountries", "MovieCountry", "movieId", "countryIso")
+	l.register("movie.releaseDates", func(ids []uint32) (map[uint32][]interface{}, error) {
+		var rows []MovieReleaseDB
+		err := db.Table(`"MLocalRelease" AS l`).
+			Select(`c."movieId", l.id, l.note, l."releaseDate", l.type, c.iso31661`).
+			Joins(`JOIN "MReleaseCountry" AS c ON c.id = l."releaseCountryId"`).
+			Where(`c."movieId" IN ?`, ids).
+			Order(`l."releaseDate"`).
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		result := map[uint32][]interface{}{}
+		for _, row := range rows {
+			result[row.MovieId] = append(result[row.MovieId], row)
+		}
+		return result, nil
+	})
+
+	l.register("tv.seasons", func(ids []uint32) (map[uint32][]interface{}, error) {
+		var rows []TVSeasonDB
+		if err := db.Table("TVSeason").Where(`"showId" IN ?`, ids).Order(`"seasonNumber"`).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		result := map[uint32][]interface{}{}
+		for _, row := range rows {
+			result[row.ShowID] = append(result[row.ShowID], row)
+		}
+		return result, nil
+	})
+	l.registerPeople("tv.creators", "TVShowCreator", "showId", "creatorId")
+	l.registerJoinIds("tv.genres", "TVShowGenre", "showId", "genreId")
+	l.registerJoinIds("tv.originCountries", "TVShowOrigCountry", "showId", "countryIso")
+	l.registerJoinIds("tv.productionCountries", "TVShowProdCountry", "showId", "countryIso")
+	l.register("tv.networks", func(ids []uint32) (map[uint32][]interface{}, error) {
+		var rows []struct {
+			ShowId   uint32 `gorm:"column:showId"`
+			ID       uint32
+			Name     string
+			LogoPath *string `gorm:"column:logoPath"`
+		}
+		err := db.Table(`"TVNetwork" AS n`).
+			Select(`j."showId", n.id, n.name, n."logoPath"`).
+			Joins(`JOIN "TVShowNetwork" AS j ON j."networkId" = n.id`).
+			Where(`j."showId" IN ?`, ids).
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		result := map[uint32][]interface{}{}
+		for _, row := range rows {
+			result[row.ShowId] = append(result[row.ShowId], Network{ID: row.ID, Name: row.Name, LogoPath: row.LogoPath})
+		}
+		return result, nil
+	})
+
+	l.register("game.byId", l.entitiesById("Game", func() interface{} { return &[]GameBase{} }))
+	l.register("tv.byId", l.entitiesById("TVShow", func() interface{} { return &[]TVShowBase{} }))
+	return l
+}
+
+func (l *graphQLLoaders) register(name string, fetch func(ids []uint32) (map[uint32][]interface{}, error)) {
+	l.loaders[name] = &relationLoader{
+		pending: map[uint32]bool{},
+		loaded:  map[uint32][]interface{}{},
+		fetch:   fetch,
+	}
+}
+
+func (l *graphQLLoaders) registerJoinIds(name string, table string, ownerColumn string, valueColumn string) {
+	l.register(name, func(ids []uint32) (map[uint32][]interface{}, error) {
+		rows, err := l.db.Table(table).Select(`"`+ownerColumn+`", "`+valueColumn+`"`).Where(`"`+ownerColumn+`" IN ?`, ids).Order(`"` + valueColumn + `"`).Rows()
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		result := map[uint32][]interface{}{}
+		for rows.Next() {
+			var owner uint32
+			var value interface{}
+			if err := rows.Scan(&owner, &value); err != nil {
+				return nil, err
+			}
+			result[owner] = append(result[owner], value)
+		}
+		return result, rows.Err()
+	})
+}
+
+func (l *graphQLLoaders) registerPeople(name string, joinTable string, ownerColumn string, personColumn string) {
+	l.register(name, func(ids []uint32) (map[uint32][]interface{}, error) {
+		var rows []struct {
+			Owner uint32
+			ID    uint32
+			Name  string
+		}
+		err := l.db.Table(`"CinemaPerson" AS p`).
+			Select(`j."`+ownerColumn+`" AS owner, p.id, p.name`).
+			Joins(`JOIN "`+joinTable+`" AS j ON j."`+personColumn+`" = p.id`).
+			Where(`j."`+ownerColumn+`" IN ?`, ids).
+			Order("p.id").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		result := map[uint32][]interface{}{}
+		for _, row := range rows {
+			result[row.Owner] = append(result[row.Owner], Person{ID: row.ID, Name: row.Name})
+		}
+		return result, nil
+	})
+}
+
+func (l *graphQLLoaders) entitiesById(table string, newRows func() interface{}) func(ids []uint32) (map[uint32][]interface{}, error) {
+	return func(ids []uint32) (map[uint32][]interface{}, error) {
+		rows := newRows()
+		if err := l.db.Table(table).Where("id IN ?", ids).Find(rows).Error; err != nil {
+			return nil, err
+		}
+		result := map[uint32][]interface{}{}
+		slice := reflect.ValueOf(rows).Elem()
+		for i := 0; i < slice.Len(); i++ {
+			row := slice.Index(i)
+			id := uint32(row.FieldByName("ID").Uint())
+			result[id] = append(result[id], row.Interface())
+		}
+		return result, nil
+	}
+}
+
+// prime registers parent IDs with every loader of the given entity type.
+func (l *graphQLLoaders) prime(entityType string, ids []uint32) {
+	for name, loader := range l.loaders {
+		if strings.HasPrefix(name, entityType+".") {
+			loader.prime(ids)
+		}
+	}
+}
+
+func (l *graphQLLoaders) load(name string, id uint32) ([]interface{}, error) {
+	return l.loaders[name].load(id)
+}
+
+func (l *relationLoader) prime(ids []uint32) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := l.loaded[id]; !ok {
+			l.pending[id] = true
+		}
+	}
+}
+
+func (l *relationLoader) load(id uint32) ([]interface{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rows, ok := l.loaded[id]; ok {
+		return rows, nil
+	}
+
+	l.pending[id] = true
+	ids := make([]uint32, 0, len(l.pending))
+	for pendingId := range l.pending {
+		ids = append(ids, pendingId)
+	}
+	result, err := l.fetch(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, pendingId := range ids {
+		l.loaded[pendingId] = result[pendingId]
+	}
+	l.pending = map[uint32]bool{}
+	return l.loaded[id], nil
+}
+
+func loadersFrom(p graphql.ResolveParams) *graphQLLoaders {
+	return p.Context.Value(graphQLLoadersKey{}).(*graphQLLoaders)
+}
+
+// Schema
+
+// dbColumnResolver resolves a GraphQL field from the struct field mapped to
+// the DB column of the same name, so the schema mirrors the DB structs.
+func dbColumnResolver(p graphql.ResolveParams) (interface{}, error) {
+	rowSchema, err := schema.Parse(p.Source, graphQLSchemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return nil, err
+	}
+	field := rowSchema.LookUpField(p.Info.FieldName)
+	if field == nil {
+		return nil, nil
+	}
+	value, _ := field.ValueOf(p.Context, reflect.Indirect(reflect.ValueOf(p.Source)))
+	return value, nil
+}
+
+func sourceId(p graphql.ResolveParams) uint32 {
+	return uint32(reflect.Indirect(reflect.ValueOf(p.Source)).FieldByName("ID").Uint())
+}
+
+func relationResolver(name string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return loadersFrom(p).load(name, sourceId(p))
+	}
+}
+
+func parentResolver(name string, idField string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		id := uint32(reflect.Indirect(reflect.ValueOf(p.Source)).FieldByName(idField).Uint())
+		rows, err := loadersFrom(p).load(name, id)
+		if err != nil || len(rows) == 0 {
+			return nil, err
+		}
+		return rows[0], nil
+	}
+}
+
+func rowIds(rows []interface{}) []uint32 {
+	ids := make([]uint32, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, uint32(reflect.Indirect(reflect.ValueOf(row)).FieldByName("ID").Uint()))
+	}
+	return ids
+}
+
+func columnFields(columns map[string]graphql.Output) graphql.Fields {
+	fields := graphql.Fields{}
+	for name, output := range columns {
+		fields[name] = &graphql.Field{Type: output, Resolve: dbColumnResolver}
+	}
+	return fields
+}
+
+func listArgs() graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"ids":    &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.Int))},
+		"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: graphQLDefaultLimit},
+		"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+	}
+}
+
+func listResolver(table string, entityType string, order string, newRows func() interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		loaders := loadersFrom(p)
+		limit, _ := p.Args["limit"].(int)
+		offset, _ := p.Args["offset"].(int)
+		query := loaders.db.Table(table).Where(`"deletedAt" IS NULL`).Order(order).Limit(graphQLEffectiveLimit(limit)).Offset(max(offset, 0))
+		if ids, ok := p.Args["ids"].([]interface{}); ok {
+			query = query.Where("id IN ?", ids)
+		}
+		rows := newRows()
+		if err := query.Find(rows).Error; err != nil {
+			return nil, err
+		}
+		slice := reflect.ValueOf(rows).Elem()
+		result := make([]interface{}, 0, slice.Len())
+		for i := 0; i < slice.Len(); i++ {
+			result = append(result, slice.Index(i).Interface())
+		}
+		loaders.prime(entityType, rowIds(result))
+		return result, nil
+	}
+}
+
+func singleResolver(table string, entityType string, newRows func() interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		loaders := loadersFrom(p)
+		query := loaders.db.Table(table).Where(`"deletedAt" IS NULL`).Limit(1)
+		if id, ok := p.Args["id"].(int); ok {
+			query = query.Where("id = ?", id)
+		} else if slug, ok := p.Args["slug"].(string); ok {
+			query = query.Where("slug = ?", slug)
+		} else {
+			return nil, fmt.Errorf("id is required")
+		}
+		rows := newRows()
+		if err := query.Find(rows).Error; err != nil {
+			return nil, err
+		}
+		slice := reflect.ValueOf(rows).Elem()
+		if slice.Len() == 0 {
+			return nil, nil
+		}
+		row := slice.Index(0).Interface()
+		loaders.prime(entityType, rowIds([]interface{}{row}))
+		return row, nil
+	}
+}
+
+func buildGraphQLSchema() (graphql.Schema, error) {
+	intList := graphql.NewList(graphql.Int)
+	stringList := graphql.NewList(graphql.String)
+
+	person := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CinemaPerson",
+		Fields: graphql.Fields{
+			"id":   &graphql.Field{Type: graphql.Int},
+			"name": &graphql.Field{Type: graphql.String},
+		},
+	})
+	network := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TVNetwork",
+		Fields: graphql.Fields{
+			"id":       &graphql.Field{Type: graphql.Int},
+			"name":     &graphql.Field{Type: graphql.String},
+			"logoPath": &graphql.Field{Type: graphql.String, Resolve: dbColumnResolver},
+		},
+	})
+
+	var game *graphql.Object
+	releaseDate := graphql.NewObject(graphql.ObjectConfig{
+		Name: "GReleaseDate",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := columnFields(map[string]graphql.Output{
+				"id":         graphql.Int,
+				"category":   graphql.Int,
+				"date":       graphql.DateTime,
+				"human":      graphql.String,
+				"m":          graphql.Int,
+				"y":          graphql.Int,
+				"statusId":   graphql.Int,
+				"platformId": graphql.Int,
+				"region":     graphql.Int,
+				"updatedAt":  graphql.DateTime,
+				"checksum":   graphql.String,
+				"gameId":     graphql.Int,
+			})
+			fields["game"] = &graphql.Field{Type: game, Resolve: parentResolver("game.byId", "GameId")}
+			return fields
+		}),
+	})
+
+	game = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Game",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := columnFields(map[string]graphql.Output{
+				"id":               graphql.Int,
+				"name":             graphql.String,
+				"slug":             graphql.String,
+				"rating":           graphql.Float,
+				"reviewsCount":     graphql.Int,
+				"category":         graphql.Int,
+				"firstReleaseDate": graphql.DateTime,
+				"follows":          graphql.Int,
+				"hypes":            graphql.Int,
+				"status":           graphql.Int,
+				"summary":          graphql.String,
+				"versionTitle":     graphql.String,
+				"updatedAt":        graphql.DateTime,
+				"checksum":         graphql.String,
+				"mainSeriesId":     graphql.Int,
+				"mainFranchiseId":  graphql.Int,
+			})
+			fields["releaseDates"] = &graphql.Field{Type: graphql.NewList(releaseDate), Resolve: relationResolver("game.releaseDates")}
+			fields["platforms"] = &graphql.Field{Type: intList, Resolve: relationResolver("game.platforms")}
+			fields["genres"] = &graphql.Field{Type: intList, Resolve: relationResolver("game.genres")}
+			fields["themes"] = &graphql.Field{Type: intList, Resolve: relationResolver("game.themes")}
+			fields["gameModes"] = &graphql.Field{Type: intList, Resolve: relationResolver("game.gameModes")}
+			fields["playerPerspectives"] = &graphql.Field{Type: intList, Resolve: relationResolver("game.playerPerspectives")}
+			fields["collections"] = &graphql.Field{Type: intList, Resolve: relationResolver("game.collections")}
+			fields["franchises"] = &graphql.Field{Type: intList, Resolve: relationResolver("game.franchises")}
+			fields["engines"] = &graphql.Field{Type: intList, Resolve: relationResolver("game.engines")}
+			return fields
+		}),
+	})
+
+	movieRelease := graphql.NewObject(graphql.ObjectConfig{
+		Name: "MLocalRelease",
+		Fields: columnFields(map[string]graphql.Output{
+			"id":          graphql.Int,
+			"note":        graphql.String,
+			"releaseDate": graphql.DateTime,
+			"type":        graphql.Int,
+			"iso31661":    graphql.String,
+		}),
+	})
+
+	movie := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Movie",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := columnFields(map[string]graphql.Output{
+				"id":                 graphql.Int,
+				"originalLanguage":   graphql.String,
+				"originaltitle":      graphql.String,
+				"title":              graphql.String,
+				"posterPath":         graphql.String,
+				"popularity":         graphql.Float,
+				"runtime":            graphql.Int,
+				"budget":             graphql.Float,
+				"primaryReleaseDate": graphql.String,
+				"overview":           graphql.String,
+				"tagline":            graphql.String,
+				"status":             graphql.String,
+				"revenue":            graphql.Float,
+				"imdbId":             graphql.String,
+				"backdropPath":       graphql.String,
+				"voteAverage":        graphql.Float,
+				"voteCount":          graphql.Int,
+				"certification":      graphql.String,
+				"collectionId":       graphql.Int,
+			})
+			fields["actors"] = &graphql.Field{Type: graphql.NewList(person), Resolve: relationResolver("movie.actors")}
+			fields["directors"] = &graphql.Field{Type: graphql.NewList(person), Resolve: relationResolver("movie.directors")}
+			fields["genres"] = &graphql.Field{Type: intList, Resolve: relationResolver("movie.genres")}
+			fields["productionCountries"] = &graphql.Field{Type: stringList, Resolve: relationResolver("movie.productionCountries")}
+			fields["releaseDates"] = &graphql.Field{Type: graphql.NewList(movieRelease), Resolve: relationResolver("movie.releaseDates")}
+			return fields
+		}),
+	})
+
+	var tvShow *graphql.Object
+	tvSeason := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TVSeason",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := columnFields(map[string]graphql.Output{
+				"id":           graphql.Int,
+				"showId":       graphql.Int,
+				"name":         graphql.String,
+				"seasonNumber": graphql.Int,
+				"posterPath":   graphql.String,
+				"airDate":      graphql.String,
+				"episodeCount": graphql.Int,
+				"voteAverage":  graphql.Float,
+			})
+			fields["show"] = &graphql.Field{Type: tvShow, Resolve: parentResolver("tv.byId", "ShowID")}
+			return fields
+		}),
+	})
+
+	tvShow = graphql.NewObject(graphql.ObjectConfig{
+		Name: "TVShow",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := columnFields(map[string]graphql.Output{
+				"id":               graphql.Int,
+				"name":             graphql.String,
+				"episodeRunTimes":  intList,
+				"firstAirDate":     graphql.String,
+				"lastAirDate":      graphql.String,
+				"inProduction":     graphql.Boolean,
+				"languages":        stringList,
+				"originalLanguage": graphql.String,
+				"originalName":     graphql.String,
+				"popularity":       graphql.Float,
+				"posterPath":       graphql.String,
+				"status":           graphql.String,
+				"type":             graphql.String,
+				"voteAverage":      graphql.Float,
+			})
+			fields["seasons"] = &graphql.Field{Type: graphql.NewList(tvSeason), Resolve: relationResolver("tv.seasons")}
+			fields["creators"] = &graphql.Field{Type: graphql.NewList(person), Resolve: relationResolver("tv.creators")}
+			fields["networks"] = &graphql.Field{Type: graphql.NewList(network), Resolve: relationResolver("tv.networks")}
+			fields["genres"] = &graphql.Field{Type: intList, Resolve: relationResolver("tv.genres")}
+			fields["originCountries"] = &graphql.Field{Type: stringList, Resolve: relationResolver("tv.originCountries")}
+			fields["productionCountries"] = &graphql.Field{Type: stringList, Resolve: relationResolver("tv.productionCountries")}
+			return fields
+		}),
+	})
+
+	newGames := func() interface{} { return &[]GameBase{} }
+	newMovies := func() interface{} { return &[]MovieDB{} }
+	newTVShows := func() interface{} { return &[]TVShowBase{} }
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"game": &graphql.Field{
+				Type: game,
+				Args: graphql.FieldConfigArgument{
+					"id":   &graphql.ArgumentConfig{Type: graphql.Int},
+					"slug": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: singleResolver("Game", "game", newGames),
+			},
+			"games": &graphql.Field{
+				Type:    graphql.NewList(game),
+				Args:    listArgs(),
+				Resolve: listResolver("Game", "game", "id", newGames),
+			},
+			"movie": &graphql.Field{
+				Type:    movie,
+				Args:    graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)}},
+				Resolve: singleResolver("Movie", "movie", newMovies),
+			},
+			"movies": &graphql.Field{
+				Type:    graphql.NewList(movie),
+				Args:    listArgs(),
+				Resolve: listResolver("Movie", "movie", "id", newMovies),
+			},
+			"tvShow": &graphql.Field{
+				Type:    tvShow,
+				Args:    graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)}},
+				Resolve: singleResolver("TVShow", "tv", newTVShows),
+			},
+			"tvShows": &graphql.Field{
+				Type:    graphql.NewList(tvShow),
+				Args:    listArgs(),
+				Resolve: listResolver("TVShow", "tv", "id", newTVShows),
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
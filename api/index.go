@@ -1,10 +1,67 @@
 package handler
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type authScope uint8
+
+const (
+	scopeRead authScope = iota
+	scopeSync
 )
 
 func Index(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeRead) {
+		return
+	}
 	fmt.Fprintf(w, "<h1>This is a GO RESTful API created to update WIITCO DB</h1>")
 }
+
+func openDB() (*gorm.DB, error) {
+	username := os.Getenv("POSTGRES_USER")
+	password := os.Getenv("POSTGRES_PASSWORD")
+	host := os.Getenv("POSTGRES_HOST")
+	port := os.Getenv("POSTGRES_PORT")
+	database := os.Getenv("POSTGRES_DATABASE")
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=require TimeZone=Asia/Shanghai",
+		host, username, password, database, port)
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{
+		PrepareStmt:            true,
+		SkipDefaultTransaction: true,
+	}, nil)
+}
+
+// authorize checks the bearer token of a request against the scope of the
+// handler and writes a 401 when it does not match. Sync and admin handlers
+// accept CRON_SECRET (sent by Vercel Cron) or SYNC_API_TOKEN. Read handlers
+// also accept READ_API_TOKEN and stay public when it is not set.
+func authorize(w http.ResponseWriter, r *http.Request, scope authScope) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ok && token != "" {
+		if matchesToken(token, os.Getenv("CRON_SECRET")) || matchesToken(token, os.Getenv("SYNC_API_TOKEN")) {
+			return true
+		}
+		if scope == scopeRead && matchesToken(token, os.Getenv("READ_API_TOKEN")) {
+			return true
+		}
+	}
+	if scope == scopeRead && os.Getenv("READ_API_TOKEN") == "" {
+		return true
+	}
+
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	return false
+}
+
+func matchesToken(token string, expected string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CollectionRef struct {
+	ID           uint32  `json:"id"`
+	Name         string  `json:"name"`
+	PosterPath   *string `json:"poster_path"`
+	BackdropPath *string `json:"backdrop_path"`
+}
+
+type MovieCollectionData struct {
+	ID           uint32           `json:"id"`
+	Name         string           `json:"name"`
+	Overview     string           `json:"overview"`
+	PosterPath   *string          `json:"poster_path"`
+	BackdropPath *string          `json:"backdrop_path"`
+	Parts        []CollectionPart `json:"parts"`
+}
+
+type CollectionPart struct {
+	ID             uint32  `json:"id"`
+	Title          string  `json:"title"`
+	PosterPath     *string `json:"poster_path"`
+	ReleaseDateStr string  `json:"release_date"`
+	Adult          bool    `json:"adult"`
+}
+
+// DB structs
+
+type MovieCollection struct {
+	ID           uint32    `json:"id" gorm:"primaryKey;autoIncrement:false"`
+	Name         string    `json:"name"`
+	Overview     *string   `json:"overview"`
+	PosterPath   *string   `json:"poster_path" gorm:"column:posterPath"`
+	BackdropPath *string   `json:"backdrop_path" gorm:"column:backdropPath"`
+	UpdatedAt    time.Time `json:"-" gorm:"column:updatedAt"`
+}
+
+type MovieCollectionPart struct {
+	CollectionId uint32  `json:"-" gorm:"primaryKey;autoIncrement:false;column:collectionId"`
+	MovieId      uint32  `json:"id" gorm:"primaryKey;autoIncrement:false;column:movieId"`
+	Title        string  `json:"title"`
+	PosterPath   *string `json:"poster_path" gorm:"column:posterPath"`
+	ReleaseDate  *string `json:"release_date" gorm:"column:releaseDate"`
+	Position     uint16  `json:"position"`
+}
+
+type MovieCollectionDetail struct {
+	MovieCollection
+	Parts []MovieCollectionPart `json:"parts"`
+}
+
+// MovieCollectionDetails returns a TMDB movie collection with its movies in
+// release order.
+func MovieCollectionDetails(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeRead) {
+		return
+	}
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Missing or invalid id", http.StatusBadRequest)
+		return
+	}
+	db, err := openDB()
+	if err != nil {
+		http.Error(w, "Error connecting to the DB", http.StatusInternalServerError)
+		return
+	}
+
+	detail, err := loadMovieCollectionDetail(db, uint32(id))
+	if err != nil {
+		http.Error(w, "Error reading movie collection", http.StatusInternalServerError)
+		return
+	}
+	if detail == nil {
+		http.Error(w, "Movie collection not found", http.StatusNotFound)
+		return
+	}
+	writeCachedJSON(w, r, detail)
+}
+
+func loadMovieCollectionDetail(db *gorm.DB, id uint32) (*MovieCollectionDetail, error) {
+	var collections []MovieCollection
+	if err := db.Table("MovieCollection").Where("id = ?", id).Limit(1).Find(&collections).Error; err != nil {
+		return nil, err
+	}
+	if len(collections) == 0 {
+		return nil, nil
+	}
+	detail := &MovieCollectionDetail{MovieCollection: collections[0], Parts: []MovieCollectionPart{}}
+	if err := db.Table("MovieCollectionPart").Where(`"collectionId" = ?`, id).Order("position").Find(&detail.Parts).Error; err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// fetchCollectionData fetches a movie collection. The caller waits on
+// moviesLimiter, see fetchSyncIds.
+func fetchCollectionData(id uint32) ([]byte, error) {
+	url := fmt.Sprintf("https://api.themoviedb.org/3/collection/%d?language=en-US", id)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("API_ACCESS_TOKEN"))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status code: %d", res.StatusCode)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// collectCollectionIds drains collectionIdCh while the movies are fetched and
+// hands over the IDs once it is closed.
+func collectCollectionIds(collectionIdCh chan uint32) chan []uint32 {
+	idsCh := make(chan []uint32, 1)
+	go func() {
+		var ids []uint32
+		for id := range collectionIdCh {
+			ids = append(ids, id)
+		}
+		idsCh <- ids
+	}()
+	return idsCh
+}
+
+// syncMovieCollections refreshes every collection referenced by the movies
+// written in this run or left pending by the last one, including the parts not
+// synced as movies yet. Collections not reached before the deadline are kept in
+// the checkpoint.
+func syncMovieCollections(db *gorm.DB, run *SyncRun, stats *syncStats, ids []uint32) {
+	idsCh := make(chan uint32, len(ids))
+	for _, id := range ids {
+		idsCh <- id
+	}
+	close(idsCh)
+	pendingIds := fetchSyncIds(run, moviesLimiter, idsCh, func(id uint32) {
+		syncMovieCollection(db, stats, id)
+	})
+	if len(pendingIds) == 0 {
+		return
+	}
+	fmt.Printf("Left %d movie collections for the next run\n", len(pendingIds))
+	if run.checkpoint == nil {
+		run.markPartial(&SyncCheckpoint{}, fmt.Sprintf("%d movie collections pending", len(pendingIds)))
+	}
+	run.checkpoint.PendingCollectionIds = pendingIds
+}
+
+func syncMovieCollection(db *gorm.DB, stats *syncStats, id uint32) {
+	body, err := fetchCollectionData(id)
+	if err != nil {
+		fmt.Printf("Error fetching movie collection %d: %v\n", id, err)
+		return
+	}
+	var collection MovieCollectionData
+	if err := decodeIngest(body, &collection, fmt.Sprintf("movie collection %d", id), stats); err != nil {
+		fmt.Println("Error parsing JSON data for movie collection:", id, err)
+		return
+	}
+	if err := writeMovieCollection(db, collection); err != nil {
+		fmt.Println("Error writing movie collection:", id, err)
+	}
+}
+
+func writeMovieCollection(db *gorm.DB, collection MovieCollectionData) error {
+	parts := collection.Parts
+	sort.SliceStable(parts, func(i, j int) bool {
+		// Unreleased parts without a date go last.
+		if parts[i].ReleaseDateStr == "" || parts[j].ReleaseDateStr == "" {
+			return parts[j].ReleaseDateStr == "" && parts[i].ReleaseDateStr != ""
+		}
+		return parts[i].ReleaseDateStr < parts[j].ReleaseDateStr
+	})
+
+	row := MovieCollection{
+		ID:           collection.ID,
+		Name:         collection.Name,
+		Overview:     filterEmptyString(collection.Overview),
+		PosterPath:   collection.PosterPath,
+		BackdropPath: collection.BackdropPath,
+		UpdatedAt:    time.Now(),
+	}
+	var partRows []MovieCollectionPart
+	movieIds := []uint32{}
+	for _, part := range parts {
+		if part.Adult {
+			continue
+		}
+		partRows = append(partRows, MovieCollectionPart{
+			CollectionId: collection.ID,
+			MovieId:      part.ID,
+			Title:        part.Title,
+			PosterPath:   part.PosterPath,
+			ReleaseDate:  filterEmptyDates(part.ReleaseDateStr),
+			Position:     uint16(len(partRows) + 1),
+		})
+		movieIds = append(movieIds, part.ID)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Table("MovieCollection").Create(&row).Error; err != nil {
+			return err
+		}
+		stale := tx.Table("MovieCollectionPart").Where(`"collectionId" = ?`, collection.ID)
+		if len(movieIds) > 0 {
+			stale = stale.Where(`"movieId" NOT IN ?`, movieIds)
+		}
+		if err := stale.Delete(&MovieCollectionPart{}).Error; err != nil {
+			return err
+		}
+		if len(partRows) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Table("MovieCollectionPart").Create(&partRows).Error
+	})
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+type MovieDetail struct {
+	ID                     uint32                 `json:"id"`
+	OriginalLanguage       *string                `json:"original_language"`
+	OriginalTitle          *string                `json:"original_title"`
+	Title                  string                 `json:"title"`
+	Language               *string                `json:"language"`
+	Overview               *string                `json:"overview"`
+	Tagline                *string                `json:"tagline"`
+	PosterPath             *string                `json:"poster_path"`
+	Popularity             float32                `json:"popularity"`
+	Runtime                uint16                 `json:"runtime"`
+	Budget                 uint64                 `json:"budget"`
+	ReleaseDateStr         *string                `json:"release_date"`
+	Status                 *string                `json:"status"`
+	Revenue                uint64                 `json:"revenue"`
+	ImdbId                 *string                `json:"imdb_id"`
+	BackdropPath           *string                `json:"backdrop_path"`
+	VoteAverage            float32                `json:"vote_average"`
+	VoteCount              uint32                 `json:"vote_count"`
+	Certification          *string                `json:"certification"`
+	Collection             *MovieCollectionDetail `json:"belongs_to_collection"`
+	Actors                 []Person               `json:"actors"`
+	Directors              []Person               `json:"directors"`
+	ReleaseCountries       []ReleaseCountry       `json:"release_dates"`
+	GenreIds               []uint32               `json:"genre_ids"`
+	ProductionCountryCodes []string               `json:"production_country_codes"`
+	ProductionCompanies    []CinemaCompany        `json:"production_companies"`
+	Videos                 []MVideo               `json:"videos"`
+	Images                 []MImage               `json:"images"`
+}
+
+// MovieDetails returns a single movie with its people, genres, production
+// companies and countries, per-country release dates, collection, videos and
+// images. JSON field names follow the ingest structs. With ?language= the
+// title, overview and tagline come from that translation, and the title falls
+// back to the original title.
+func MovieDetails(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeRead) {
+		return
+	}
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Missing or invalid id", http.StatusBadRequest)
+		return
+	}
+	db, err := openDB()
+	if err != nil {
+		http.Error(w, "Error connecting to the DB", http.StatusInternalServerError)
+		return
+	}
+
+	var base []MovieDB
+	if err := db.Table("Movie").Where(`id = ? AND "deletedAt" IS NULL`, id).Limit(1).Find(&base).Error; err != nil {
+		http.Error(w, "Error reading movie", http.StatusInternalServerError)
+		return
+	}
+	if len(base) == 0 {
+		http.Error(w, "Movie not found", http.StatusNotFound)
+		return
+	}
+
+	detail, err := loadMovieDetail(db, base[0])
+	if err != nil {
+		http.Error(w, "Error reading movie relations", http.StatusInternalServerError)
+		return
+	}
+	if language := r.URL.Query().Get("language"); language != "" {
+		if err := applyMovieTranslation(db, &detail, language); err != nil {
+			http.Error(w, "Error reading movie translation", http.StatusInternalServerError)
+			return
+		}
+	}
+	writeCachedJSON(w, r, detail)
+}
+
+func loadCinemaPeople(db *gorm.DB, joinTable string, personColumn string, ownerColumn string, ownerId uint32) ([]Person, error) {
+	people := []Person{}
+	err := db.Table("CinemaPerson").
+		Where(`id IN (SELECT "`+personColumn+`" FROM "`+joinTable+`" WHERE "`+ownerColumn+`" = ?)`, ownerId).
+		Order("id").
+		Find(&people).Error
+	return people, err
+}
+
+func loadMovieDetail(db *gorm.DB, movie MovieDB) (MovieDetail, error) {
+	detail := MovieDetail{
+		ID:                     movie.ID,
+		OriginalLanguage:       movie.OriginalLanguage,
+		OriginalTitle:          movie.OriginalTitle,
+		Title:                  movie.Title,
+		PosterPath:             movie.PosterPath,
+		Popularity:             movie.Popularity,
+		Runtime:                movie.Runtime,
+		Budget:                 movie.Budget,
+		ReleaseDateStr:         normalizeDatePtr(movie.ReleaseDateStr),
+		Overview:               movie.Overview,
+		Tagline:                movie.Tagline,
+		Status:                 movie.Status,
+		Revenue:                movie.Revenue,
+		ImdbId:                 movie.ImdbId,
+		BackdropPath:           movie.BackdropPath,
+		VoteAverage:            movie.VoteAverage,
+		VoteCount:              movie.VoteCount,
+		Certification:          movie.Certification,
+		ReleaseCountries:       []ReleaseCountry{},
+		GenreIds:               []uint32{},
+		ProductionCountryCodes: []string{},
+		Videos:                 []MVideo{},
+		Images:                 []MImage{},
+	}
+
+	var err error
+	if movie.CollectionId != nil {
+		if detail.Collection, err = loadMovieCollectionDetail(db, *movie.CollectionId); err != nil {
+			return detail, err
+		}
+	}
+	if detail.Actors, err = loadCinemaPeople(db, "MovieActor", "actorId", "movieId", movie.ID); err != nil {
+		return detail, err
+	}
+	if detail.Directors, err = loadCinemaPeople(db, "MovieDirector", "directorId", "movieId", movie.ID); err != nil {
+		return detail, err
+	}
+	if err := db.Table("MovieGenre").Where(`"movieId" = ?`, movie.ID).Order(`"genreId"`).Pluck(`"genreId"`, &detail.GenreIds).Error; err != nil {
+		return detail, err
+	}
+	if err := db.Table("MovieCountry").Where(`"movieId" = ?`, movie.ID).Order(`"countryIso"`).Pluck(`"countryIso"`, &detail.ProductionCountryCodes).Error; err != nil {
+		return detail, err
+	}
+	if detail.ProductionCompanies, err = loadCinemaCompanies(db, "MovieCompany", "movieId", movie.ID); err != nil {
+		return detail, err
+	}
+
+	if err := db.Table("MVideo").Where(`"movieId" = ?`, movie.ID).Order(`official DESC, "publishedAt" DESC`).Find(&detail.Videos).Error; err != nil {
+		return detail, err
+	}
+	if err := db.Table("MImage").Where(`"movieId" = ?`, movie.ID).Order(`type, "voteAverage" DESC`).Find(&detail.Images).Error; err != nil {
+		return detail, err
+	}
+
+	var releaseCountries []MReleaseCountry
+	if err := db.Table("MReleaseCountry").Where(`"movieId" = ?`, movie.ID).Order("iso31661").Find(&releaseCountries).Error; err != nil {
+		return detail, err
+	}
+	releaseCountryIds := make([]uint32, 0, len(releaseCountries))
+	for _, releaseCountry := range releaseCountries {
+		releaseCountryIds = append(releaseCountryIds, releaseCountry.ID)
+	}
+	var localReleases []MLocalRelease
+	if len(releaseCountryIds) > 0 {
+		if err := db.Table("MLocalRelease").Where(`"releaseCountryId" IN ?`, releaseCountryIds).Order(`"releaseDate"`).Find(&localReleases).Error; err != nil {
+			return detail, err
+		}
+	}
+	localReleasesByCountry := map[uint32][]MLocalRelease{}
+	for _, localRelease := range localReleases {
+		localReleasesByCountry[localRelease.ReleaseCountryId] = append(localReleasesByCountry[localRelease.ReleaseCountryId], localRelease)
+	}
+	for _, releaseCountry := range releaseCountries {
+		country := ReleaseCountry{
+			ISO31661:          releaseCountry.ISO31661,
+			LocalReleaseDates: []LocalReleaseDate{},
+		}
+		for _, localRelease := range localReleasesByCountry[releaseCountry.ID] {
+			item := LocalReleaseDate{
+				ReleaseDate: localRelease.ReleaseDate,
+				Type:        localRelease.Type,
+			}
+			if localRelease.Note != nil {
+				item.Note = *localRelease.Note
+			}
+			country.LocalReleaseDates = append(country.LocalReleaseDates, item)
+		}
+		detail.ReleaseCountries = append(detail.ReleaseCountries, country)
+	}
+
+	return detail, nil
+}
+
+func applyMovieTranslation(db *gorm.DB, detail *MovieDetail, language string) error {
+	var translations []MovieTranslation
+	if err := db.Table("MovieTranslation").Where(`"movieId" = ? AND language = ?`, detail.ID, language).Limit(1).Find(&translations).Error; err != nil {
+		return err
+	}
+	detail.Language = &language
+	if len(translations) == 0 {
+		if detail.OriginalTitle != nil {
+			detail.Title = *detail.OriginalTitle
+		}
+		return nil
+	}
+	detail.Title = translations[0].Title
+	if translations[0].Overview != nil {
+		detail.Overview = translations[0].Overview
+	}
+	if translations[0].Tagline != nil {
+		detail.Tagline = translations[0].Tagline
+	}
+	return nil
+}
@@ -2,26 +2,30 @@ package handler
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"golang.org/x/time/rate"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
-	"strconv"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type Response struct {
 	Results      []MovieIndex `json:"results"`
-	Page         uint16       `json:"page"`
-	TotalPages   uint16       `json:"total_pages"`
-	TotalResults uint16       `json:"total_results"`
+	Page         uint32       `json:"page"`
+	TotalPages   uint32       `json:"total_pages"`
+	TotalResults uint32       `json:"total_results"`
 }
 
 type MovieIndex struct {
@@ -30,32 +34,57 @@ type MovieIndex struct {
 }
 
 type Movie struct {
-	ID                  uint32              `json:"id"`
-	OriginalLanguage    *string             `json:"original_language"`
-	OriginalTitle       *string             `json:"original_title"`
-	Title               string              `json:"title"`
-	PosterPath          *string             `json:"poster_path"`
-	Popularity          float32             `json:"popularity"`
-	Runtime             uint16              `json:"runtime"`
-	Budget              uint32              `json:"budget"`
-	ReleaseDateStr      string              `json:"release_date"`
-	Actors              []Person            `json:"actors"`
-	Directors           []Person            `json:"directors"`
-	ReleaseCountries    []ReleaseCountry    `json:"release_dates"`
-	Genres              []Genre             `json:"genres"`
-	ProductionCountries []ProductionCountry `json:"production_countries"`
+	ID                  uint32               `json:"id"`
+	OriginalLanguage    *string              `json:"original_language"`
+	OriginalTitle       *string              `json:"original_title"`
+	Title               string               `json:"title"`
+	PosterPath          *string              `json:"poster_path"`
+	Popularity          float32              `json:"popularity"`
+	Runtime             uint16               `json:"runtime"`
+	Budget              uint64               `json:"budget"`
+	ReleaseDateStr      string               `json:"release_date"`
+	Overview            string               `json:"overview"`
+	Tagline             string               `json:"tagline"`
+	Status              string               `json:"status"`
+	Revenue             uint64               `json:"revenue"`
+	ImdbId              *string              `json:"imdb_id"`
+	BackdropPath        *string              `json:"backdrop_path"`
+	VoteAverage         float32              `json:"vote_average"`
+	VoteCount           uint32               `json:"vote_count"`
+	BelongsToCollection *CollectionRef       `json:"belongs_to_collection"`
+	Actors              []Person             `json:"actors"`
+	Directors           []Person             `json:"directors"`
+	ReleaseDates        MovieReleaseDates    `json:"release_dates"`
+	Genres              []Genre              `json:"genres"`
+	ProductionCountries []ProductionCountry  `json:"production_countries"`
+	ProductionCompanies []Company            `json:"production_companies"`
+	Translations        MediaTranslations    `json:"translations"`
+	WatchProviders      WatchProviderResults `json:"watch/providers"`
+	Videos              MediaVideos          `json:"videos"`
+	Images              MediaImages          `json:"images"`
 }
 
 type MovieDB struct {
-	ID               uint32  `json:"id"`
-	OriginalLanguage *string `json:"original_language" gorm:"column:originalLanguage"`
-	OriginalTitle    *string `json:"original_title" gorm:"column:originaltitle"`
-	Title            string  `json:"title"`
-	PosterPath       *string `json:"poster_path" gorm:"column:posterPath"`
-	Popularity       float32 `json:"popularity"`
-	Runtime          uint16  `json:"runtime"`
-	Budget           uint32  `json:"budget"`
-	ReleaseDateStr   *string `json:"release_date" gorm:"column:primaryReleaseDate"`
+	ID               uint32     `json:"id"`
+	OriginalLanguage *string    `json:"original_language" gorm:"column:originalLanguage"`
+	OriginalTitle    *string    `json:"original_title" gorm:"column:originaltitle"`
+	Title            string     `json:"title"`
+	PosterPath       *string    `json:"poster_path" gorm:"column:posterPath"`
+	Popularity       float32    `json:"popularity"`
+	Runtime          uint16     `json:"runtime"`
+	Budget           uint64     `json:"budget"`
+	ReleaseDateStr   *string    `json:"release_date" gorm:"column:primaryReleaseDate"`
+	Overview         *string    `json:"overview"`
+	Tagline          *string    `json:"tagline"`
+	Status           *string    `json:"status"`
+	Revenue          uint64     `json:"revenue"`
+	ImdbId           *string    `json:"imdb_id" gorm:"column:imdbId"`
+	BackdropPath     *string    `json:"backdrop_path" gorm:"column:backdropPath"`
+	VoteAverage      float32    `json:"vote_average" gorm:"column:voteAverage"`
+	VoteCount        uint32     `json:"vote_count" gorm:"column:voteCount"`
+	Certification    *string    `json:"certification"`
+	CollectionId     *uint32    `json:"collection_id" gorm:"column:collectionId"`
+	DeletedAt        *time.Time `json:"-" gorm:"column:deletedAt"`
 }
 
 type Genre struct {
@@ -63,15 +92,20 @@ type Genre struct {
 	Name string `json:"name"`
 }
 
+type MovieReleaseDates struct {
+	Results []ReleaseCountry `json:"results"`
+}
+
 type ReleaseCountry struct {
-	ISO31661          string             `json:"iso_639_1"`
-	LocalReleaseDates []LocalReleaseDate `json:"local_release_dates"`
+	ISO31661          string             `json:"iso_3166_1"`
+	LocalReleaseDates []LocalReleaseDate `json:"release_dates"`
 }
 
 type LocalReleaseDate struct {
-	Note        string    `json:"note"`
-	ReleaseDate time.Time `json:"release_date"`
-	Type        uint8     `json:"type"`
+	Certification string    `json:"certification"`
+	Note          string    `json:"note"`
+	ReleaseDate   time.Time `json:"release_date"`
+	Type          uint8     `json:"type"`
 }
 
 type ProductionCountry struct {
@@ -84,6 +118,101 @@ type Person struct {
 	Name string `json:"name"`
 }
 
+type MediaTranslations struct {
+	Translations []MediaTranslation `json:"translations"`
+}
+
+type MediaTranslation struct {
+	ISO31661 string               `json:"iso_3166_1"`
+	ISO6391  string               `json:"iso_639_1"`
+	Data     MediaTranslationData `json:"data"`
+}
+
+type MediaTranslationData struct {
+	Title    string `json:"title"`
+	Name     string `json:"name"`
+	Overview string `json:"overview"`
+	Tagline  string `json:"tagline"`
+}
+
+type MediaVideos struct {
+	Results []MediaVideoData `json:"results"`
+}
+
+type MediaVideoData struct {
+	ID          string     `json:"id"`
+	ISO6391     string     `json:"iso_639_1"`
+	Name        string     `json:"name"`
+	Key         string     `json:"key"`
+	Site        string     `json:"site"`
+	Type        string     `json:"type"`
+	Official    bool       `json:"official"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+type MediaImages struct {
+	Backdrops []MediaImageData `json:"backdrops"`
+	Logos     []MediaImageData `json:"logos"`
+}
+
+type MediaImageData struct {
+	FilePath    string  `json:"file_path"`
+	ISO6391     *string `json:"iso_639_1"`
+	Width       uint16  `json:"width"`
+	Height      uint16  `json:"height"`
+	AspectRatio float32 `json:"aspect_ratio"`
+	VoteAverage float32 `json:"vote_average"`
+}
+
+// MediaVideo and MediaImage hold the columns shared by the movie and TV show
+// media tables. Videos keep the games' video_id name for the YouTube key.
+type MediaVideo struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	Name        *string    `json:"name"`
+	VideoId     string     `json:"video_id" gorm:"column:videoId"`
+	Site        string     `json:"site"`
+	Type        string     `json:"type"`
+	Language    *string    `json:"language"`
+	Official    bool       `json:"official"`
+	PublishedAt *time.Time `json:"published_at" gorm:"column:publishedAt"`
+}
+
+type MediaImage struct {
+	FilePath    string  `json:"file_path" gorm:"primaryKey;column:filePath"`
+	Type        string  `json:"type"`
+	Language    *string `json:"language"`
+	Width       uint16  `json:"width"`
+	Height      uint16  `json:"height"`
+	AspectRatio float32 `json:"aspect_ratio" gorm:"column:aspectRatio"`
+	VoteAverage float32 `json:"vote_average" gorm:"column:voteAverage"`
+}
+
+type MVideo struct {
+	MediaVideo
+	MovieId uint32 `json:"-" gorm:"column:movieId;index"`
+}
+
+type MImage struct {
+	MovieId uint32 `json:"-" gorm:"primaryKey;autoIncrement:false;column:movieId"`
+	MediaImage
+}
+
+// movieMedia carries every video and image of one movie, so the writer can
+// replace them as a whole.
+type movieMedia struct {
+	MovieId uint32
+	Videos  []MVideo
+	Images  []MImage
+}
+
+type MovieTranslation struct {
+	MovieId  uint32 `gorm:"primaryKey;autoIncrement:false;column:movieId"`
+	Language string `gorm:"primaryKey"`
+	Title    string
+	Overview *string
+	Tagline  *string
+}
+
 type MovieActor struct {
 	MovieId uint32 `gorm:"column:movieId"`
 	ActorId uint32 `gorm:"column:actorId"`
@@ -110,25 +239,108 @@ type MReleaseCountry struct {
 	MovieId  uint32 `gorm:"column:movieId"`
 }
 
+// movieReleases carries every release country and date of one movie, so the
+// writer can replace them as a whole.
+type movieReleases struct {
+	MovieId   uint32
+	Countries []MReleaseCountry
+	Releases  []MLocalRelease
+}
+
+// movieReleaseKey is the natural key of a release date. Row IDs come from a
+// sequence, so stored and incoming rows are matched on this instead.
+type movieReleaseKey struct {
+	MovieId  uint32
+	ISO31661 string
+	Type     uint8
+	Date     int64
+}
+
+type MovieReleaseDB struct {
+	MovieId     uint32 `gorm:"column:movieId"`
+	ID          uint32
+	Note        *string
+	ReleaseDate time.Time `gorm:"column:releaseDate"`
+	Type        uint8
+	ISO31661    string `gorm:"column:iso31661"`
+}
+
+type MediaContentHash struct {
+	EntityType string `gorm:"column:entityType;primaryKey"`
+	EntityId   uint32 `gorm:"column:entityId;primaryKey;autoIncrement:false"`
+	Hash       string
+	UpdatedAt  time.Time `gorm:"column:updatedAt"`
+}
+
+type MediaChanges struct {
+	Changes []MediaChange `json:"changes"`
+}
+
+type MediaChange struct {
+	Key   string            `json:"key"`
+	Items []MediaChangeItem `json:"items"`
+}
+
+type MediaChangeItem struct {
+	ID            string          `json:"id"`
+	Action        string          `json:"action"`
+	Time          string          `json:"time"`
+	ISO6391       string          `json:"iso_639_1"`
+	ISO31661      string          `json:"iso_3166_1"`
+	Value         json.RawMessage `json:"value"`
+	OriginalValue json.RawMessage `json:"original_value"`
+}
+
+type MediaChangeLog struct {
+	ID            string `gorm:"primaryKey"`
+	EntityType    string `gorm:"column:entityType;index:idx_media_change_log_entity"`
+	EntityId      uint32 `gorm:"column:entityId;index:idx_media_change_log_entity"`
+	Key           string
+	Action        string
+	Time          time.Time
+	ISO6391       *string `gorm:"column:iso6391"`
+	ISO31661      *string `gorm:"column:iso31661"`
+	Value         *string `gorm:"type:jsonb"`
+	OriginalValue *string `gorm:"column:originalValue;type:jsonb"`
+}
+
 type MLocalRelease struct {
 	ID               uint32
 	Note             *string
 	ReleaseDate      time.Time `gorm:"column:releaseDate"`
 	Type             uint8
 	ReleaseCountryId uint32 `gorm:"column:releaseCountryId"`
+	ISO31661         string `json:"-" gorm:"-"`
 }
 
 var (
+	errNotFound          = errors.New("not found upstream")
 	moviesLimiter        = rate.NewLimiter(rate.Every(time.Second/40), 1)
-	totalPages    uint16 = 500
+	totalPages    uint32 = 500
 )
 
 func Movies(w http.ResponseWriter, r *http.Request) {
-	updateMovies()
+	if !authorize(w, r, scopeSync) {
+		return
+	}
+	run, err := updateMovies()
+	if err != nil {
+		var running *syncRunningError
+		if errors.As(err, &running) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error updating movies DB", http.StatusInternalServerError)
+		return
+	}
+	if run.Partial {
+		fmt.Fprintf(w, "Partially updated movies DB, resume from %s", *run.ResumeFrom)
+		return
+	}
 	fmt.Fprintf(w, "Finished updating movies DB")
 }
 
-func fetchIndexData(PageNum uint16) ([]byte, error) {
+func fetchIndexData(PageNum uint32) ([]byte, error) {
 	if err := moviesLimiter.Wait(context.Background()); err != nil {
 		fmt.Printf("Rate limit exceeded for Page %d: %v\n", PageNum, err)
 	}
@@ -155,14 +367,14 @@ func fetchIndexData(PageNum uint16) ([]byte, error) {
 	return body, nil
 }
 
-func fetchAndProcessIndexData(pageNum uint16, idsCh chan uint32) {
+func fetchAndProcessIndexData(pageNum uint32, filter ContentFilter, stats *syncStats, idsCh chan uint32) {
 	body, err := fetchIndexData(pageNum)
 	if err != nil {
 		fmt.Printf("Error fetching the first index page: %v\n", err)
 		return
 	}
 	var rawInitData Response
-	err = json.Unmarshal(body, &rawInitData)
+	err = decodeIngest(body, &rawInitData, fmt.Sprintf("movie changes page %d", pageNum), stats)
 	if err != nil {
 		fmt.Printf("Error unmarshalling the first index page: %v\n", err)
 		return
@@ -171,18 +383,53 @@ func fetchAndProcessIndexData(pageNum uint16, idsCh chan uint32) {
 		totalPages = rawInitData.TotalPages
 	}
 	for _, entry := range rawInitData.Results {
-		if !entry.Adult {
-			idsCh <- entry.ID
+		if filter.ExcludeAdult && entry.Adult {
+			stats.markFiltered(entry.ID, "adult")
+			stats.markDeleted(entry.ID)
+			continue
 		}
+		idsCh <- entry.ID
 	}
 }
 
+// fetchDetailsData fetches the details of a movie. The caller waits on
+// moviesLimiter, see fetchSyncIds.
 func fetchDetailsData(id uint32) ([]byte, error) {
-	if err := moviesLimiter.Wait(context.Background()); err != nil {
-		fmt.Printf("Rate limit exceeded for Page %d: %v\n", id, err)
+	url := fmt.Sprintf("https://api.themoviedb.org/3/movie/%d?append_to_response=release_dates%%2Ccredits%%2Ctranslations%%2Cwatch%%2Fproviders%%2Cvideos%%2Cimages&language=en-US&%s", id, mediaLanguageQuery())
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("API_ACCESS_TOKEN"))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status code: %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
 
-	url := fmt.Sprintf("https://api.themoviedb.org/3/movie/%d?append_to_response=relese_dates%%2Ccredits&language=en-US", id)
+func changeLogEnabled() bool {
+	return os.Getenv("TMDB_CHANGE_LOG") == "true"
+}
+
+func fetchChangesData(mediaType string, id uint32, limiter *rate.Limiter) ([]byte, error) {
+	if err := limiter.Wait(context.Background()); err != nil {
+		fmt.Printf("Rate limit exceeded for ID %d: %v\n", id, err)
+	}
+
+	url := fmt.Sprintf("https://api.themoviedb.org/3/%s/%d/changes", mediaType, id)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -204,6 +451,66 @@ func fetchDetailsData(id uint32) ([]byte, error) {
 	return body, nil
 }
 
+func fetchAndProcessChangesData(mediaType string, id uint32, limiter *rate.Limiter, changeLogCh chan MediaChangeLog) {
+	body, err := fetchChangesData(mediaType, id, limiter)
+	if err != nil {
+		fmt.Printf("Error fetching changes for ID %d: %v\n", id, err)
+		return
+	}
+	var changes MediaChanges
+	err = json.Unmarshal(body, &changes)
+	if err != nil {
+		fmt.Println("Error parsing changes JSON data for ID:", id, err)
+		return
+	}
+
+	for _, change := range changes.Changes {
+		for _, item := range change.Items {
+			changedAt, err := time.Parse("2006-01-02 15:04:05 MST", item.Time)
+			if err != nil {
+				fmt.Printf("Error parsing change time %q for ID %d: %v\n", item.Time, id, err)
+				continue
+			}
+
+			changeLogCh <- MediaChangeLog{
+				ID:            item.ID,
+				EntityType:    mediaType,
+				EntityId:      id,
+				Key:           change.Key,
+				Action:        item.Action,
+				Time:          changedAt,
+				ISO6391:       filterEmptyDates(item.ISO6391),
+				ISO31661:      filterEmptyDates(item.ISO31661),
+				Value:         rawJSONValue(item.Value),
+				OriginalValue: rawJSONValue(item.OriginalValue),
+			}
+		}
+	}
+}
+
+func rawJSONValue(input json.RawMessage) *string {
+	if len(input) == 0 {
+		return nil
+	}
+	value := string(input)
+	return &value
+}
+
+func contentHash(rows any) string {
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func storedContentHash(db *gorm.DB, entityType string, id uint32) string {
+	var hash string
+	db.Table("MediaContentHash").Select("hash").Where(`"entityType" = ? AND "entityId" = ?`, entityType, id).Scan(&hash)
+	return hash
+}
+
 func filterEmptyDates(input string) *string {
 	if input != "" {
 		return &input
@@ -212,108 +519,361 @@ func filterEmptyDates(input string) *string {
 	}
 }
 
-func fetchAndProcessDetailsData(id uint32, movieBaseCh chan MovieDB, peopleRefCh chan Person, actorCh chan MovieActor, directorCh chan MovieDirector, genreCh chan MovieGenre, countryCh chan MovieCountry, releaseCountryCh chan MReleaseCountry, localReleaseCh chan MLocalRelease) {
+func filterEmptyString(input string) *string {
+	if input == "" {
+		return nil
+	}
+	return &input
+}
+
+// movieReleaseRows flattens the release dates of a movie, dropping repeated
+// countries and dates TMDB occasionally lists twice.
+func movieReleaseRows(movieId uint32, releaseCountries []ReleaseCountry) ([]MReleaseCountry, []MLocalRelease) {
+	var countries []MReleaseCountry
+	var releases []MLocalRelease
+	seenCountries := map[string]bool{}
+	seenReleases := map[movieReleaseKey]bool{}
+	for _, releaseCountry := range releaseCountries {
+		if !seenCountries[releaseCountry.ISO31661] {
+			seenCountries[releaseCountry.ISO31661] = true
+			countries = append(countries, MReleaseCountry{
+				MovieId:  movieId,
+				ISO31661: releaseCountry.ISO31661,
+			})
+		}
+		for _, localRelease := range releaseCountry.LocalReleaseDates {
+			key := movieReleaseKey{movieId, releaseCountry.ISO31661, localRelease.Type, localRelease.ReleaseDate.UnixMilli()}
+			if seenReleases[key] {
+				continue
+			}
+			seenReleases[key] = true
+			releases = append(releases, MLocalRelease{
+				Note:        filterEmptyString(localRelease.Note),
+				ReleaseDate: localRelease.ReleaseDate,
+				Type:        localRelease.Type,
+				ISO31661:    releaseCountry.ISO31661,
+			})
+		}
+	}
+	return countries, releases
+}
+
+// tmdbLanguages returns the languages to store translations for, from the
+// comma-separated TMDB_LANGUAGES (e.g. "en-US,de-DE,ja"). Entries without a
+// region match any translation in that language.
+func tmdbLanguages() []string {
+	var languages []string
+	for _, language := range strings.Split(os.Getenv("TMDB_LANGUAGES"), ",") {
+		if language = strings.TrimSpace(language); language != "" {
+			languages = append(languages, language)
+		}
+	}
+	if len(languages) == 0 {
+		return []string{"en-US"}
+	}
+	return languages
+}
+
+type languageTranslation struct {
+	Language    string
+	Translation MediaTranslation
+}
+
+// translationsByLanguage picks the translation stored under each configured
+// language. An entry with a region only matches that exact tag. An entry
+// without one matches every region TMDB lists for the language, and the first
+// listed is kept, so each media has one row per language.
+func translationsByLanguage(translations []MediaTranslation, languages []string) []languageTranslation {
+	var picked []languageTranslation
+	for _, language := range languages {
+		if slices.ContainsFunc(picked, func(p languageTranslation) bool { return p.Language == language }) {
+			continue
+		}
+		for _, translation := range translations {
+			if translation.ISO6391+"-"+translation.ISO31661 == language || translation.ISO6391 == language {
+				picked = append(picked, languageTranslation{Language: language, Translation: translation})
+				break
+			}
+		}
+	}
+	return picked
+}
+
+// mediaLanguageQuery limits appended videos and images to the TMDB_LANGUAGES
+// languages plus the ones without a language, such as most backdrops.
+func mediaLanguageQuery() string {
+	languages := []string{}
+	for _, language := range tmdbLanguages() {
+		language = strings.Split(language, "-")[0]
+		if !slices.Contains(languages, language) {
+			languages = append(languages, language)
+		}
+	}
+	include := url.QueryEscape(strings.Join(append(languages, "null"), ","))
+	return "include_image_language=" + include + "&include_video_language=" + include
+}
+
+func mediaVideos(videos MediaVideos) []MediaVideo {
+	var rows []MediaVideo
+	for _, video := range videos.Results {
+		rows = append(rows, MediaVideo{
+			ID:          video.ID,
+			Name:        filterEmptyString(video.Name),
+			VideoId:     video.Key,
+			Site:        video.Site,
+			Type:        video.Type,
+			Language:    filterEmptyString(video.ISO6391),
+			Official:    video.Official,
+			PublishedAt: video.PublishedAt,
+		})
+	}
+	return rows
+}
+
+func mediaImages(images MediaImages) []MediaImage {
+	var rows []MediaImage
+	for _, group := range []struct {
+		Type   string
+		Images []MediaImageData
+	}{
+		{"backdrop", images.Backdrops},
+		{"logo", images.Logos},
+	} {
+		for _, image := range group.Images {
+			rows = append(rows, MediaImage{
+				FilePath:    image.FilePath,
+				Type:        group.Type,
+				Language:    image.ISO6391,
+				Width:       image.Width,
+				Height:      image.Height,
+				AspectRatio: image.AspectRatio,
+				VoteAverage: image.VoteAverage,
+			})
+		}
+	}
+	return rows
+}
+
+// movieCertification returns the certification for TMDB_CERTIFICATION_COUNTRY
+// (US by default), preferring the theatrical release over other types.
+func movieCertification(releaseCountries []ReleaseCountry) *string {
+	country := os.Getenv("TMDB_CERTIFICATION_COUNTRY")
+	if country == "" {
+		country = "US"
+	}
+	var certification string
+	for _, releaseCountry := range releaseCountries {
+		if releaseCountry.ISO31661 != country {
+			continue
+		}
+		for _, localRelease := range releaseCountry.LocalReleaseDates {
+			if localRelease.Certification == "" {
+				continue
+			}
+			if localRelease.Type == 3 {
+				return &localRelease.Certification
+			}
+			if certification == "" {
+				certification = localRelease.Certification
+			}
+		}
+	}
+	return filterEmptyString(certification)
+}
+
+type movieRows struct {
+	Base             MovieDB
+	People           []Person
+	Actors           []MovieActor
+	Directors        []MovieDirector
+	Genres           []MovieGenre
+	Countries        []MovieCountry
+	CompanyRefs      []CinemaCompany
+	Companies        []MovieCompany
+	ReleaseCountries []MReleaseCountry
+	LocalReleases    []MLocalRelease
+	Translations     []MovieTranslation
+	WatchProviders   []MediaWatchProvider
+	Videos           []MVideo
+	Images           []MImage
+}
+
+func fetchAndProcessDetailsData(id uint32, db *gorm.DB, filter ContentFilter, stats *syncStats, hashCh chan MediaContentHash, changeLogCh chan MediaChangeLog, movieBaseCh chan MovieDB, peopleRefCh chan Person, actorCh chan MovieActor, directorCh chan MovieDirector, genreCh chan MovieGenre, countryCh chan MovieCountry, companyRefCh chan CinemaCompany, companyCh chan MovieCompany, releaseCh chan movieReleases, translationCh chan MovieTranslation, watchProviderCh chan mediaWatchProviders, mediaCh chan movieMedia, collectionIdCh chan uint32) {
 	body, err := fetchDetailsData(id)
+	if errors.Is(err, errNotFound) {
+		stats.markDeleted(id)
+		return
+	}
 	if err != nil {
 		fmt.Printf("Error fetching details for ID %d: %v\n", id, err)
 		return
 	}
 	var movie Movie
-	err = json.Unmarshal(body, &movie)
+	err = decodeIngest(body, &movie, fmt.Sprintf("movie %d", id), stats)
 	if err != nil {
 		fmt.Println("Error parsing JSON data for Movie ID:", id, err)
 		return
 	}
 
-	movieBaseCh <- MovieDB{
-		ID:               movie.ID,
-		OriginalLanguage: movie.OriginalLanguage,
-		OriginalTitle:    movie.OriginalTitle,
-		Title:            movie.Title,
-		PosterPath:       movie.PosterPath,
-		Popularity:       movie.Popularity,
-		Runtime:          movie.Runtime,
-		Budget:           movie.Budget,
-		ReleaseDateStr:   filterEmptyDates(movie.ReleaseDateStr),
+	if reason := filter.mediaReason(movie.OriginalLanguage, movie.Popularity); reason != "" {
+		stats.markFiltered(id, reason)
+		return
 	}
 
-	for _, actor := range movie.Actors {
-		peopleRefCh <- actor
+	rows := movieRows{
+		Base: MovieDB{
+			ID:               movie.ID,
+			OriginalLanguage: movie.OriginalLanguage,
+			OriginalTitle:    movie.OriginalTitle,
+			Title:            movie.Title,
+			PosterPath:       movie.PosterPath,
+			Popularity:       movie.Popularity,
+			Runtime:          movie.Runtime,
+			Budget:           movie.Budget,
+			ReleaseDateStr:   filterEmptyDates(movie.ReleaseDateStr),
+			Overview:         filterEmptyString(movie.Overview),
+			Tagline:          filterEmptyString(movie.Tagline),
+			Status:           filterEmptyString(movie.Status),
+			Revenue:          movie.Revenue,
+			ImdbId:           movie.ImdbId,
+			BackdropPath:     movie.BackdropPath,
+			VoteAverage:      movie.VoteAverage,
+			VoteCount:        movie.VoteCount,
+			Certification:    movieCertification(movie.ReleaseDates.Results),
+		},
+	}
+	if movie.BelongsToCollection != nil {
+		rows.Base.CollectionId = &movie.BelongsToCollection.ID
+	}
 
-		actorCh <- MovieActor{
+	for _, actor := range movie.Actors {
+		rows.People = append(rows.People, actor)
+		rows.Actors = append(rows.Actors, MovieActor{
 			MovieId: movie.ID,
 			ActorId: actor.ID,
-		}
+		})
 	}
 
 	for _, director := range movie.Directors {
-		peopleRefCh <- director
-
-		directorCh <- MovieDirector{
+		rows.People = append(rows.People, director)
+		rows.Directors = append(rows.Directors, MovieDirector{
 			MovieId:    movie.ID,
 			DirectorId: director.ID,
-		}
+		})
 	}
 
 	for _, genre := range movie.Genres {
-		genreCh <- MovieGenre{
+		rows.Genres = append(rows.Genres, MovieGenre{
 			MovieId: movie.ID,
 			GenreId: genre.ID,
-		}
+		})
 	}
 
 	for _, country := range movie.ProductionCountries {
-		countryCh <- MovieCountry{
+		rows.Countries = append(rows.Countries, MovieCountry{
 			MovieId:    movie.ID,
 			CountryIso: country.ISO31661,
-		}
+		})
 	}
 
-	for i, releaseCountry := range movie.ReleaseCountries {
-		releaseCountryIdString := strconv.Itoa(int(movie.ID)) + strconv.Itoa(i)
-		releaseCountryId, _ := strconv.Atoi(releaseCountryIdString)
+	for _, company := range movie.ProductionCompanies {
+		rows.CompanyRefs = append(rows.CompanyRefs, companyRef(company))
+		rows.Companies = append(rows.Companies, MovieCompany{
+			MovieId:   movie.ID,
+			CompanyId: company.ID,
+		})
+	}
 
-		for n, localRelease := range releaseCountry.LocalReleaseDates {
-			localReleaseIdString := strconv.Itoa(int(movie.ID)) + strconv.Itoa(i)
-			localReleaseIdPreInt, _ := strconv.Atoi(localReleaseIdString)
-			localReleaseId := localReleaseIdPreInt + n
+	rows.ReleaseCountries, rows.LocalReleases = movieReleaseRows(movie.ID, movie.ReleaseDates.Results)
 
-			var localReleaseNote *string
+	for _, picked := range translationsByLanguage(movie.Translations.Translations, tmdbLanguages()) {
+		translation := picked.Translation
+		title := translation.Data.Title
+		if title == "" && movie.OriginalTitle != nil {
+			title = *movie.OriginalTitle
+		}
+		rows.Translations = append(rows.Translations, MovieTranslation{
+			MovieId:  movie.ID,
+			Language: picked.Language,
+			Title:    title,
+			Overview: filterEmptyString(translation.Data.Overview),
+			Tagline:  filterEmptyString(translation.Data.Tagline),
+		})
+	}
+	rows.WatchProviders = watchProviderRows("movie", movie.ID, movie.WatchProviders)
+	for _, video := range mediaVideos(movie.Videos) {
+		rows.Videos = append(rows.Videos, MVideo{MediaVideo: video, MovieId: movie.ID})
+	}
+	for _, image := range mediaImages(movie.Images) {
+		rows.Images = append(rows.Images, MImage{MovieId: movie.ID, MediaImage: image})
+	}
 
-			if localRelease.Note != "" {
-				localReleaseNote = &localRelease.Note
-			}
+	// Popularity moves on nearly every crawl and would defeat the skip, so it is
+	// only refreshed when something else changed.
+	hashed := rows
+	hashed.Base.Popularity = 0
+	hash := contentHash(hashed)
+	if hash != "" && hash == storedContentHash(db, "movie", movie.ID) {
+		stats.skipped.Add(1)
+		return
+	}
 
-			localReleaseCh <- MLocalRelease{
-				ID:               uint32(localReleaseId),
-				Note:             localReleaseNote,
-				ReleaseDate:      localRelease.ReleaseDate,
-				Type:             localRelease.Type,
-				ReleaseCountryId: uint32(releaseCountryId),
-			}
-		}
+	if changeLogEnabled() {
+		fetchAndProcessChangesData("movie", movie.ID, moviesLimiter, changeLogCh)
+	}
 
-		releaseCountryCh <- MReleaseCountry{
-			ID:       uint32(releaseCountryId),
-			MovieId:  movie.ID,
-			ISO31661: releaseCountry.ISO31661,
-		}
+	movieBaseCh <- rows.Base
+	for _, person := range rows.People {
+		peopleRefCh <- person
+	}
+	for _, actor := range rows.Actors {
+		actorCh <- actor
+	}
+	for _, director := range rows.Directors {
+		directorCh <- director
+	}
+	for _, genre := range rows.Genres {
+		genreCh <- genre
+	}
+	for _, country := range rows.Countries {
+		countryCh <- country
+	}
+	for _, company := range rows.CompanyRefs {
+		companyRefCh <- company
+	}
+	for _, company := range rows.Companies {
+		companyCh <- company
+	}
+	releaseCh <- movieReleases{MovieId: movie.ID, Countries: rows.ReleaseCountries, Releases: rows.LocalReleases}
+	for _, translation := range rows.Translations {
+		translationCh <- translation
+	}
+	watchProviderCh <- mediaWatchProviders{EntityType: "movie", EntityId: movie.ID, Providers: rows.WatchProviders}
+	mediaCh <- movieMedia{MovieId: movie.ID, Videos: rows.Videos, Images: rows.Images}
+	if rows.Base.CollectionId != nil {
+		collectionIdCh <- *rows.Base.CollectionId
+	}
+	hashCh <- MediaContentHash{
+		EntityType: "movie",
+		EntityId:   movie.ID,
+		Hash:       hash,
+		UpdatedAt:  time.Now(),
 	}
 }
 
-func updateMovies() {
+func updateMovies() (*SyncRun, error) {
 	fmt.Printf("Started updating movies at %s \n", time.Now().Format("15:04:05"))
-	username := os.Getenv("POSTGRES_USER")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	host := os.Getenv("POSTGRES_HOST")
-	port := os.Getenv("POSTGRES_PORT")
-	database := os.Getenv("POSTGRES_DATABASE")
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=require TimeZone=Asia/Shanghai",
-		host, username, password, database, port)
-	db, _ := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		PrepareStmt:            true,
-		SkipDefaultTransaction: true,
-	}, nil)
+	db, err := openDB()
+	if err != nil {
+		fmt.Println("Error connecting to the DB:", err)
+		return nil, err
+	}
+	run, err := startSyncRun(db, "movies")
+	if err != nil {
+		return nil, err
+	}
+	var stats syncStats
+	filter := loadContentFilter(db, "movies")
 
 	const batchSize = 500
 	idsCh := make(chan uint32, 20000)
@@ -323,62 +883,99 @@ func updateMovies() {
 	directorCh := make(chan MovieDirector, 100000)
 	genreCh := make(chan MovieGenre, 50000)
 	countryCh := make(chan MovieCountry, 100000)
-	releaseCountryCh := make(chan MReleaseCountry, 1000000)
-	localReleaseCh := make(chan MLocalRelease, 1000000)
+	companyRefCh := make(chan CinemaCompany, 100000)
+	companyCh := make(chan MovieCompany, 100000)
+	releaseCh := make(chan movieReleases, 100000)
+	hashCh := make(chan MediaContentHash, 20000)
+	changeLogCh := make(chan MediaChangeLog, 200000)
+	translationCh := make(chan MovieTranslation, 100000)
+	watchProviderCh := make(chan mediaWatchProviders, 20000)
+	mediaCh := make(chan movieMedia, 20000)
+	collectionIdCh := make(chan uint32, 20000)
+	hashesCh := collectContentHashes(hashCh)
+	collectionIdsCh := collectCollectionIds(collectionIdCh)
 
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		fetchAndProcessIndexData(1, idsCh)
+		fetchAndProcessIndexData(1, filter, &stats, idsCh)
 	}()
 	wg.Wait()
 
+	// skippedPage is written before idsCh is closed and read after it drains.
+	var skippedPage uint32
+	checkpoint := loadSyncCheckpoint(db, "movies")
+	if len(checkpoint.PendingIds) > 0 {
+		fmt.Printf("Resuming movies sync with %d pending IDs\n", len(checkpoint.PendingIds))
+	}
+	if len(checkpoint.PendingCollectionIds) > 0 {
+		fmt.Printf("Resuming movies sync with %d pending collections\n", len(checkpoint.PendingCollectionIds))
+	}
+
 	go func() {
-		var wgFetch sync.WaitGroup
-		var i uint16
-		for i = 2; i <= totalPages; i++ {
-			wgFetch.Add(1)
-			go func(i uint16) {
-				defer wgFetch.Done()
-				fetchAndProcessIndexData(i, idsCh)
-			}(i)
-		}
-		wgFetch.Wait()
+		for _, id := range checkpoint.PendingIds {
+			idsCh <- uint32(id)
+		}
+		skippedPage = fetchIndexPages(run, 2, totalPages, func(pageNum uint32) {
+			fetchAndProcessIndexData(pageNum, filter, &stats, idsCh)
+		})
 		close(idsCh)
 	}()
 
 	go func() {
-		var wgDetails sync.WaitGroup
-		for id := range idsCh {
-			wgDetails.Add(1)
-			go func(id uint32) {
-				defer wgDetails.Done()
-				fetchAndProcessDetailsData(id, movieBaseCh, peopleRefCh, actorCh, directorCh, genreCh, countryCh, releaseCountryCh, localReleaseCh)
-			}(id)
-		}
-		wgDetails.Wait()
+		pendingIds := fetchSyncIds(run, moviesLimiter, idsCh, func(id uint32) {
+			fetchAndProcessDetailsData(id, db, filter, &stats, hashCh, changeLogCh, movieBaseCh, peopleRefCh, actorCh, directorCh, genreCh, countryCh, companyRefCh, companyCh, releaseCh, translationCh, watchProviderCh, mediaCh, collectionIdCh)
+		})
+		markIdsPartial(run, pendingIds, skippedPage)
 		close(movieBaseCh)
 		close(peopleRefCh)
 		close(actorCh)
 		close(directorCh)
 		close(genreCh)
 		close(countryCh)
-		close(releaseCountryCh)
-		close(localReleaseCh)
+		close(companyRefCh)
+		close(companyCh)
+		close(releaseCh)
+		close(translationCh)
+		close(watchProviderCh)
+		close(mediaCh)
+		close(collectionIdCh)
+		close(hashCh)
+		close(changeLogCh)
+	}()
+
+	// Rows that do not reference a parent row are written while the details
+	// are still fetched, their channels would otherwise fill up and block it.
+	var wgDrain sync.WaitGroup
+	wgDrain.Add(1)
+	go func() {
+		defer wgDrain.Done()
+		writeChangeLogRows(db, changeLogCh, batchSize)
+	}()
+	wgDrain.Add(1)
+	go func() {
+		defer wgDrain.Done()
+		writeWatchProviderRows(db, watchProviderCh, batchSize, &stats)
+	}()
+	wgDrain.Add(1)
+	go func() {
+		defer wgDrain.Done()
+		writeMovieMediaRows(db, mediaCh, batchSize, &stats)
 	}()
 
 	var wgWriteBase sync.WaitGroup
 	wgWriteBase.Add(1)
 	go func() {
 		defer wgWriteBase.Done()
-		writeMovieBaseRows(db, movieBaseCh, batchSize)
+		writeMovieBaseRows(db, movieBaseCh, batchSize, &stats)
 	}()
 
 	wgWriteBase.Add(1)
 	go func() {
 		defer wgWriteBase.Done()
-		writePeopleRefRows(db, peopleRefCh, batchSize)
+		writePeopleRefRows(db, peopleRefCh, batchSize, &stats)
+		writeCompanyRefRows(db, companyRefCh, batchSize, &stats)
 	}()
 	wgWriteBase.Wait()
 
@@ -386,8 +983,8 @@ func updateMovies() {
 	wgWrite.Add(1)
 	go func() {
 		defer wgWrite.Done()
-		writeMovieActorRows(db, actorCh, batchSize)
-		writeMovieDirectorRows(db, directorCh, batchSize)
+		writeMovieActorRows(db, actorCh, batchSize, &stats)
+		writeMovieDirectorRows(db, directorCh, batchSize, &stats)
 	}()
 	wgWrite.Wait()
 
@@ -395,9 +992,10 @@ func updateMovies() {
 	wgWriteSecond.Add(1)
 	go func() {
 		defer wgWriteSecond.Done()
-		writeMovieGenreRows(db, genreCh, batchSize)
-		writeMovieCountryRows(db, countryCh, batchSize)
-		writeReleaseCountryRows(db, releaseCountryCh, batchSize)
+		writeMovieGenreRows(db, genreCh, batchSize, &stats)
+		writeMovieCountryRows(db, countryCh, batchSize, &stats)
+		writeMovieCompanyRows(db, companyCh, batchSize, &stats)
+		writeMovieReleaseRows(db, releaseCh, batchSize, &stats)
 	}()
 	wgWriteSecond.Wait()
 
@@ -405,21 +1003,36 @@ func updateMovies() {
 	wgWriteChild.Add(1)
 	go func() {
 		defer wgWriteChild.Done()
-		writeLocalReleaseRows(db, localReleaseCh, batchSize)
+		writeMovieTranslationRows(db, translationCh, batchSize, &stats)
 	}()
 	wgWriteChild.Wait()
 	wg.Wait()
 
+	wgDrain.Wait()
+	writeContentHashRows(db, <-hashesCh, batchSize, &stats)
+	collectionIds := <-collectionIdsCh
+	for _, id := range checkpoint.PendingCollectionIds {
+		collectionIds = append(collectionIds, uint32(id))
+	}
+	syncMovieCollections(db, run, &stats, collectionIds)
+	syncWatchProviders(db, "movie", moviesLimiter, &stats)
+	run.Deleted = tombstoneEntities(db, "Movie", "movie", stats.deletedIds)
+	finishSyncRun(db, run, &stats)
+
 	fmt.Println("Successfully fetched data and written to the DB")
+	return run, nil
 }
 
-func writeMovieBaseRows(db *gorm.DB, dataChannel chan MovieDB, batchSize int) {
+func writeMovieBaseRows(db *gorm.DB, dataChannel chan MovieDB, batchSize int, stats *syncStats) {
 	var batch []MovieDB
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writeMovieBasesBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.ID)
+				}
 			}
 			batch = []MovieDB{}
 		}
@@ -428,11 +1041,31 @@ func writeMovieBaseRows(db *gorm.DB, dataChannel chan MovieDB, batchSize int) {
 	if len(batch) > 0 {
 		if err := writeMovieBasesBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.ID)
+			}
 		}
 	}
 }
 func writeMovieBasesBatch(db *gorm.DB, objects []MovieDB) error {
 	return db.Transaction(func(tx *gorm.DB) error {
+		ids := make([]uint32, 0, len(objects))
+		for _, object := range objects {
+			ids = append(ids, object.ID)
+		}
+		payloads, err := createdEntityPayloads(tx, "Movie", "movie", ids)
+		if err != nil {
+			return err
+		}
+		if err := enqueueWebhookEvents(tx, payloads); err != nil {
+			return err
+		}
+		if err := recordOutboxEvents(tx, "Movie", "movie", objects); err != nil {
+			return err
+		}
+		if err := recordPrimaryReleaseDateChanges(tx, objects); err != nil {
+			return err
+		}
 		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{UpdateAll: true}).Table("Movie").Model(&MovieDB{}).Create(&objects).Error; err != nil {
 			return err
 		}
@@ -440,13 +1073,14 @@ func writeMovieBasesBatch(db *gorm.DB, objects []MovieDB) error {
 	})
 }
 
-func writePeopleRefRows(db *gorm.DB, dataChannel chan Person, batchSize int) {
+func writePeopleRefRows(db *gorm.DB, dataChannel chan Person, batchSize int, stats *syncStats) {
 	var batch []Person
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writePeopleRefsBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				stats.markRefsFailed()
 			}
 			batch = []Person{}
 		}
@@ -455,6 +1089,7 @@ func writePeopleRefRows(db *gorm.DB, dataChannel chan Person, batchSize int) {
 	if len(batch) > 0 {
 		if err := writePeopleRefsBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			stats.markRefsFailed()
 		}
 	}
 }
@@ -467,13 +1102,16 @@ func writePeopleRefsBatch(db *gorm.DB, objects []Person) error {
 	})
 }
 
-func writeMovieActorRows(db *gorm.DB, dataChannel chan MovieActor, batchSize int) {
+func writeMovieActorRows(db *gorm.DB, dataChannel chan MovieActor, batchSize int, stats *syncStats) {
 	var batch []MovieActor
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writeActorsBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.MovieId)
+				}
 			}
 			batch = []MovieActor{}
 		}
@@ -482,6 +1120,9 @@ func writeMovieActorRows(db *gorm.DB, dataChannel chan MovieActor, batchSize int
 	if len(batch) > 0 {
 		if err := writeActorsBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.MovieId)
+			}
 		}
 	}
 }
@@ -495,13 +1136,16 @@ func writeActorsBatch(db *gorm.DB, objects []MovieActor) error {
 	})
 }
 
-func writeMovieDirectorRows(db *gorm.DB, dataChannel chan MovieDirector, batchSize int) {
+func writeMovieDirectorRows(db *gorm.DB, dataChannel chan MovieDirector, batchSize int, stats *syncStats) {
 	var batch []MovieDirector
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writeDirectorsBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.MovieId)
+				}
 			}
 			batch = []MovieDirector{}
 		}
@@ -510,6 +1154,9 @@ func writeMovieDirectorRows(db *gorm.DB, dataChannel chan MovieDirector, batchSi
 	if len(batch) > 0 {
 		if err := writeDirectorsBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.MovieId)
+			}
 		}
 	}
 }
@@ -523,13 +1170,16 @@ func writeDirectorsBatch(db *gorm.DB, objects []MovieDirector) error {
 	})
 }
 
-func writeMovieGenreRows(db *gorm.DB, dataChannel chan MovieGenre, batchSize int) {
+func writeMovieGenreRows(db *gorm.DB, dataChannel chan MovieGenre, batchSize int, stats *syncStats) {
 	var batch []MovieGenre
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writeGenresBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.MovieId)
+				}
 			}
 			batch = []MovieGenre{}
 		}
@@ -538,6 +1188,9 @@ func writeMovieGenreRows(db *gorm.DB, dataChannel chan MovieGenre, batchSize int
 	if len(batch) > 0 {
 		if err := writeGenresBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.MovieId)
+			}
 		}
 	}
 }
@@ -551,13 +1204,16 @@ func writeGenresBatch(db *gorm.DB, objects []MovieGenre) error {
 	})
 }
 
-func writeMovieCountryRows(db *gorm.DB, dataChannel chan MovieCountry, batchSize int) {
+func writeMovieCountryRows(db *gorm.DB, dataChannel chan MovieCountry, batchSize int, stats *syncStats) {
 	var batch []MovieCountry
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writeCountriesBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.MovieId)
+				}
 			}
 			batch = []MovieCountry{}
 		}
@@ -566,6 +1222,9 @@ func writeMovieCountryRows(db *gorm.DB, dataChannel chan MovieCountry, batchSize
 	if len(batch) > 0 {
 		if err := writeCountriesBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.MovieId)
+			}
 		}
 	}
 }
@@ -579,58 +1238,342 @@ func writeCountriesBatch(db *gorm.DB, objects []MovieCountry) error {
 	})
 }
 
-func writeReleaseCountryRows(db *gorm.DB, dataChannel chan MReleaseCountry, batchSize int) {
-	var batch []MReleaseCountry
+func writeMovieCompanyRows(db *gorm.DB, dataChannel chan MovieCompany, batchSize int, stats *syncStats) {
+	var batch []MovieCompany
+	for entry := range dataChannel {
+		batch = append(batch, entry)
+		if len(batch) >= batchSize {
+			if err := writeMovieCompaniesBatch(db, batch); err != nil {
+				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.MovieId)
+				}
+			}
+			batch = []MovieCompany{}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := writeMovieCompaniesBatch(db, batch); err != nil {
+			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.MovieId)
+			}
+		}
+	}
+}
+func writeMovieCompaniesBatch(db *gorm.DB, objects []MovieCompany) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{DoNothing: true}).Table("MovieCompany").Model(&MovieCompany{}).Create(&objects).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func writeMovieReleaseRows(db *gorm.DB, dataChannel chan movieReleases, batchSize int, stats *syncStats) {
+	var batch []movieReleases
+	for entry := range dataChannel {
+		batch = append(batch, entry)
+		if len(batch) >= batchSize {
+			if err := writeMovieReleasesBatch(db, batch); err != nil {
+				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.MovieId)
+				}
+			}
+			batch = []movieReleases{}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := writeMovieReleasesBatch(db, batch); err != nil {
+			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.MovieId)
+			}
+		}
+	}
+}
+
+// writeMovieReleasesBatch replaces the release countries and dates of every
+// movie in the batch. Rows are upserted on their natural key and new ones take
+// their ID from a sequence, so IDs neither collide between movies nor move
+// when TMDB reorders a list.
+func writeMovieReleasesBatch(db *gorm.DB, objects []movieReleases) error {
+	movieIds := make([]uint32, 0, len(objects))
+	wantedCountries := map[string]bool{}
+	wanted := map[movieReleaseKey]bool{}
+	countries := []map[string]interface{}{}
+	for _, object := range objects {
+		movieIds = append(movieIds, object.MovieId)
+		for _, country := range object.Countries {
+			wantedCountries[fmt.Sprintf("%d/%s", object.MovieId, country.ISO31661)] = true
+			countries = append(countries, map[string]interface{}{
+				"id":       gorm.Expr(`nextval('"MReleaseCountry_id_seq"')`),
+				"movieId":  object.MovieId,
+				"iso31661": country.ISO31661,
+			})
+		}
+		for _, release := range object.Releases {
+			wanted[movieReleaseKey{object.MovieId, release.ISO31661, release.Type, release.ReleaseDate.UnixMilli()}] = true
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		stored, err := loadMovieReleases(tx, movieIds)
+		if err != nil {
+			return err
+		}
+		if len(countries) > 0 {
+			err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "movieId"}, {Name: "iso31661"}}, DoNothing: true}).
+				Table("MReleaseCountry").CreateInBatches(&countries, 1000).Error
+			if err != nil {
+				return err
+			}
+		}
+		var storedCountries []MReleaseCountry
+		if err := tx.Table("MReleaseCountry").Where(`"movieId" IN ?`, movieIds).Find(&storedCountries).Error; err != nil {
+			return err
+		}
+		countryIds := map[string]uint32{}
+		var staleCountryIds []uint32
+		for _, country := range storedCountries {
+			key := fmt.Sprintf("%d/%s", country.MovieId, country.ISO31661)
+			countryIds[key] = country.ID
+			if !wantedCountries[key] {
+				staleCountryIds = append(staleCountryIds, country.ID)
+			}
+		}
+
+		releases := []map[string]interface{}{}
+		for _, object := range objects {
+			for _, release := range object.Releases {
+				releases = append(releases, map[string]interface{}{
+					"id":               gorm.Expr(`nextval('"MLocalRelease_id_seq"')`),
+					"note":             release.Note,
+					"releaseDate":      release.ReleaseDate,
+					"type":             release.Type,
+					"releaseCountryId": countryIds[fmt.Sprintf("%d/%s", object.MovieId, release.ISO31661)],
+				})
+			}
+		}
+		if len(releases) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "releaseCountryId"}, {Name: "type"}, {Name: "releaseDate"}},
+				DoUpdates: clause.AssignmentColumns([]string{"note"}),
+			}).Table("MLocalRelease").CreateInBatches(&releases, 1000).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		current, err := loadMovieReleases(tx, movieIds)
+		if err != nil {
+			return err
+		}
+		if err := recordMovieReleaseDateChanges(tx, objects, stored, current); err != nil {
+			return err
+		}
+
+		var staleIds []uint32
+		for _, release := range current {
+			if !wanted[release.key()] {
+				staleIds = append(staleIds, release.ID)
+			}
+		}
+		if len(staleIds) > 0 {
+			if err := tx.Table("MLocalRelease").Where("id IN ?", staleIds).Delete(&MLocalRelease{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(staleCountryIds) > 0 {
+			if err := tx.Table("MReleaseCountry").Where("id IN ?", staleCountryIds).Delete(&MReleaseCountry{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func loadMovieReleases(tx *gorm.DB, movieIds []uint32) ([]MovieReleaseDB, error) {
+	var rows []MovieReleaseDB
+	err := tx.Table(`"MLocalRelease" AS l`).
+		Select(`c."movieId", l.id, l.note, l."releaseDate", l.type, c.iso31661`).
+		Joins(`JOIN "MReleaseCountry" AS c ON c.id = l."releaseCountryId"`).
+		Where(`c."movieId" IN ?`, movieIds).
+		Order(`l."releaseDate", l.id`).
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (release MovieReleaseDB) key() movieReleaseKey {
+	return movieReleaseKey{release.MovieId, release.ISO31661, release.Type, release.ReleaseDate.UnixMilli()}
+}
+
+// collectContentHashes drains hashCh while the rows are written and hands
+// over every queued hash once it is closed.
+func collectContentHashes(dataChannel chan MediaContentHash) chan []MediaContentHash {
+	hashesCh := make(chan []MediaContentHash, 1)
+	go func() {
+		var hashes []MediaContentHash
+		for entry := range dataChannel {
+			hashes = append(hashes, entry)
+		}
+		hashesCh <- hashes
+	}()
+	return hashesCh
+}
+
+// writeContentHashRows stores the hashes of the entities whose rows were all
+// written. It runs after every writer finished, the entities of a failed batch
+// keep their old hash and are written again by the next run.
+func writeContentHashRows(db *gorm.DB, hashes []MediaContentHash, batchSize int, stats *syncStats) {
+	var batch []MediaContentHash
+	for _, entry := range hashes {
+		if stats.writeFailed(entry.EntityId) {
+			continue
+		}
+		batch = append(batch, entry)
+		if len(batch) >= batchSize {
+			if err := writeContentHashesBatch(db, batch); err != nil {
+				fmt.Println("Error writing batch:", err)
+			}
+			batch = []MediaContentHash{}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := writeContentHashesBatch(db, batch); err != nil {
+			fmt.Println("Error writing final batch:", err)
+		}
+	}
+}
+
+func writeContentHashesBatch(db *gorm.DB, objects []MediaContentHash) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{UpdateAll: true}).Table("MediaContentHash").Model(&MediaContentHash{}).Create(&objects).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func writeChangeLogRows(db *gorm.DB, dataChannel chan MediaChangeLog, batchSize int) {
+	var batch []MediaChangeLog
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
-			if err := writeReleaseCountriesBatch(db, batch); err != nil {
+			if err := writeChangeLogsBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
 			}
-			batch = []MReleaseCountry{}
+			batch = []MediaChangeLog{}
 		}
 	}
 
 	if len(batch) > 0 {
-		if err := writeReleaseCountriesBatch(db, batch); err != nil {
+		if err := writeChangeLogsBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
 		}
 	}
 }
 
-func writeReleaseCountriesBatch(db *gorm.DB, objects []MReleaseCountry) error {
+func writeChangeLogsBatch(db *gorm.DB, objects []MediaChangeLog) error {
 	return db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{DoNothing: true}).Table("MReleaseCountry").Model(&MReleaseCountry{}).Create(&objects).Error; err != nil {
+		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{DoNothing: true}).Table("MediaChangeLog").Model(&MediaChangeLog{}).Create(&objects).Error; err != nil {
 			return err
 		}
 		return nil
 	})
 }
 
-func writeLocalReleaseRows(db *gorm.DB, dataChannel chan MLocalRelease, batchSize int) {
-	var batch []MLocalRelease
+func writeMovieTranslationRows(db *gorm.DB, dataChannel chan MovieTranslation, batchSize int, stats *syncStats) {
+	var batch []MovieTranslation
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
-			if err := writeLocalReleasesBatch(db, batch); err != nil {
+			if err := writeMovieTranslationsBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.MovieId)
+				}
 			}
-			batch = []MLocalRelease{}
+			batch = []MovieTranslation{}
 		}
 	}
 
 	if len(batch) > 0 {
-		if err := writeLocalReleasesBatch(db, batch); err != nil {
+		if err := writeMovieTranslationsBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.MovieId)
+			}
 		}
 	}
 }
 
-func writeLocalReleasesBatch(db *gorm.DB, objects []MLocalRelease) error {
+func writeMovieTranslationsBatch(db *gorm.DB, objects []MovieTranslation) error {
 	return db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{DoNothing: true}).Table("MLocalRelease").Model(&MLocalRelease{}).Create(&objects).Error; err != nil {
+		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{UpdateAll: true}).Table("MovieTranslation").Model(&MovieTranslation{}).Create(&objects).Error; err != nil {
 			return err
 		}
 		return nil
 	})
 }
+
+func writeMovieMediaRows(db *gorm.DB, dataChannel chan movieMedia, batchSize int, stats *syncStats) {
+	var batch []movieMedia
+	for entry := range dataChannel {
+		batch = append(batch, entry)
+		if len(batch) >= batchSize {
+			if err := writeMovieMediaBatch(db, batch); err != nil {
+				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.MovieId)
+				}
+			}
+			batch = []movieMedia{}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := writeMovieMediaBatch(db, batch); err != nil {
+			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.MovieId)
+			}
+		}
+	}
+}
+
+// writeMovieMediaBatch replaces the videos and images of every movie in the
+// batch, dropping the ones TMDB no longer lists.
+func writeMovieMediaBatch(db *gorm.DB, objects []movieMedia) error {
+	ids := make([]uint32, 0, len(objects))
+	var videos []MVideo
+	var images []MImage
+	for _, object := range objects {
+		ids = append(ids, object.MovieId)
+		videos = append(videos, object.Videos...)
+		images = append(images, object.Images...)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("MVideo").Where(`"movieId" IN ?`, ids).Delete(&MVideo{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("MImage").Where(`"movieId" IN ?`, ids).Delete(&MImage{}).Error; err != nil {
+			return err
+		}
+		if len(videos) > 0 {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Table("MVideo").CreateInBatches(&videos, 1000).Error; err != nil {
+				return err
+			}
+		}
+		if len(images) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Table("MImage").CreateInBatches(&images, 1000).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
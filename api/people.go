@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PersonData struct {
+	ID                 uint32  `json:"id"`
+	Name               string  `json:"name"`
+	ProfilePath        *string `json:"profile_path"`
+	KnownForDepartment string  `json:"known_for_department"`
+	Birthday           *string `json:"birthday"`
+	PlaceOfBirth       *string `json:"place_of_birth"`
+	Popularity         float32 `json:"popularity"`
+}
+
+// PersonDB is a full CinemaPerson row. The movie and TV syncs only write the
+// id and name of the people they reference.
+type PersonDB struct {
+	ID                 uint32     `json:"id"`
+	Name               string     `json:"name"`
+	ProfilePath        *string    `json:"profile_path" gorm:"column:profilePath"`
+	KnownForDepartment *string    `json:"known_for_department" gorm:"column:knownForDepartment"`
+	Birthday           *string    `json:"birthday"`
+	PlaceOfBirth       *string    `json:"place_of_birth" gorm:"column:placeOfBirth"`
+	Popularity         float32    `json:"popularity"`
+	SyncedAt           *time.Time `json:"-" gorm:"column:syncedAt"`
+}
+
+const (
+	// Referenced people never fetched yet are filled in this many per run on
+	// top of the changes feed.
+	peopleBackfillLimit = 5000
+)
+
+var (
+	peopleLimiter = rate.NewLimiter(rate.Every(time.Second/40), 1)
+)
+
+func People(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeSync) {
+		return
+	}
+	run, err := updatePeople()
+	if err != nil {
+		var running *syncRunningError
+		if errors.As(err, &running) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error updating people DB", http.StatusInternalServerError)
+		return
+	}
+	if run.Partial {
+		fmt.Fprintf(w, "Partially updated people DB, resume from %s", *run.ResumeFrom)
+		return
+	}
+	fmt.Fprintf(w, "Finished updating people DB")
+}
+
+func fetchPeopleIndexData(pageNum uint32) ([]byte, error) {
+	if err := peopleLimiter.Wait(context.Background()); err != nil {
+		fmt.Printf("Rate limit exceeded for Page %d: %v\n", pageNum, err)
+	}
+
+	url := fmt.Sprintf("https://api.themoviedb.org/3/person/changes?page=%d", pageNum)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("API_ACCESS_TOKEN"))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status code: %d", res.StatusCode)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// fetchAndProcessPeopleIndexData queues the changed people that are already
+// referenced by a movie or TV show and returns the number of feed pages.
+func fetchAndProcessPeopleIndexData(db *gorm.DB, pageNum uint32, stats *syncStats, idsCh chan uint32) uint32 {
+	body, err := fetchPeopleIndexData(pageNum)
+	if err != nil {
+		fmt.Printf("Error fetching people changes page %d: %v\n", pageNum, err)
+		return 0
+	}
+	var page Response
+	if err := decodeIngest(body, &page, fmt.Sprintf("people changes page %d", pageNum), stats); err != nil {
+		fmt.Printf("Error unmarshalling people changes page %d: %v\n", pageNum, err)
+		return 0
+	}
+	if len(page.Results) == 0 {
+		return page.TotalPages
+	}
+
+	ids := make([]uint32, 0, len(page.Results))
+	for _, entry := range page.Results {
+		ids = append(ids, entry.ID)
+	}
+	var known []uint32
+	if err := db.Table("CinemaPerson").Where("id IN ?", ids).Pluck("id", &known).Error; err != nil {
+		fmt.Printf("Error matching people changes page %d: %v\n", pageNum, err)
+		return page.TotalPages
+	}
+	for _, id := range known {
+		idsCh <- id
+	}
+	return page.TotalPages
+}
+
+func unsyncedPeopleIds(db *gorm.DB) []uint32 {
+	var ids []uint32
+	err := db.Table("CinemaPerson").
+		Where(`"syncedAt" IS NULL`).
+		Order("id").
+		Limit(peopleBackfillLimit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		fmt.Println("Error reading unsynced people:", err)
+	}
+	return ids
+}
+
+// fetchPersonData fetches the details of a person. The caller waits on
+// peopleLimiter, see fetchSyncIds.
+func fetchPersonData(id uint32) ([]byte, error) {
+	url := fmt.Sprintf("https://api.themoviedb.org/3/person/%d?language=en-US", id)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("API_ACCESS_TOKEN"))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status code: %d", res.StatusCode)
+	}
+	return io.ReadAll(res.Body)
+}
+
+func fetchAndProcessPersonData(id uint32, db *gorm.DB, stats *syncStats, personCh chan PersonDB, hashCh chan MediaContentHash) {
+	body, err := fetchPersonData(id)
+	if errors.Is(err, errNotFound) {
+		// Credits still point at the person, so the stub stays and is only
+		// marked as synced to keep it out of the backfill.
+		if err := db.Table("CinemaPerson").Where("id = ?", id).Update("syncedAt", time.Now()).Error; err != nil {
+			fmt.Printf("Error marking person %d as synced: %v\n", id, err)
+		}
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error fetching person %d: %v\n", id, err)
+		return
+	}
+	var person PersonData
+	if err := decodeIngest(body, &person, fmt.Sprintf("person %d", id), stats); err != nil {
+		fmt.Println("Error parsing JSON data for person:", id, err)
+		return
+	}
+
+	syncedAt := time.Now()
+	row := PersonDB{
+		ID:                 person.ID,
+		Name:               person.Name,
+		ProfilePath:        person.ProfilePath,
+		KnownForDepartment: filterEmptyString(person.KnownForDepartment),
+		Birthday:           person.Birthday,
+		PlaceOfBirth:       person.PlaceOfBirth,
+		Popularity:         person.Popularity,
+		SyncedAt:           &syncedAt,
+	}
+	// The hash covers what TMDB sent, without the sync time. Popularity moves
+	// on nearly every crawl and is left out like for movies and TV.
+	hashed := person
+	hashed.Popularity = 0
+	hash := contentHash(hashed)
+	if hash != "" && hash == storedContentHash(db, "person", person.ID) {
+		stats.skipped.Add(1)
+		return
+	}
+
+	personCh <- row
+	hashCh <- MediaContentHash{
+		EntityType: "person",
+		EntityId:   person.ID,
+		Hash:       hash,
+		UpdatedAt:  syncedAt,
+	}
+}
+
+func updatePeople() (*SyncRun, error) {
+	fmt.Printf("Started updating people at %s \n", time.Now().Format("15:04:05"))
+	db, err := openDB()
+	if err != nil {
+		fmt.Println("Error connecting to the DB:", err)
+		return nil, err
+	}
+	run, err := startSyncRun(db, "people")
+	if err != nil {
+		return nil, err
+	}
+	var stats syncStats
+
+	const batchSize = 500
+	idsCh := make(chan uint32, 20000)
+	personCh := make(chan PersonDB, 20000)
+	hashCh := make(chan MediaContentHash, 20000)
+	hashesCh := collectContentHashes(hashCh)
+
+	// skippedPage is written before idsCh is closed and read after it drains.
+	var skippedPage uint32
+	checkpoint := loadSyncCheckpoint(db, "people")
+	if len(checkpoint.PendingIds) > 0 {
+		fmt.Printf("Resuming people sync with %d pending IDs\n", len(checkpoint.PendingIds))
+	}
+
+	go func() {
+		for _, id := range checkpoint.PendingIds {
+			idsCh <- uint32(id)
+		}
+		pages := fetchAndProcessPeopleIndexData(db, 1, &stats, idsCh)
+		skippedPage = fetchIndexPages(run, 2, pages, func(pageNum uint32) {
+			fetchAndProcessPeopleIndexData(db, pageNum, &stats, idsCh)
+		})
+		for _, id := range unsyncedPeopleIds(db) {
+			idsCh <- id
+		}
+		close(idsCh)
+	}()
+
+	go func() {
+		pendingIds := fetchSyncIds(run, peopleLimiter, idsCh, func(id uint32) {
+			fetchAndProcessPersonData(id, db, &stats, personCh, hashCh)
+		})
+		markIdsPartial(run, pendingIds, skippedPage)
+		close(personCh)
+		close(hashCh)
+	}()
+
+	writePersonRows(db, personCh, batchSize, &stats)
+	writeContentHashRows(db, <-hashesCh, batchSize, &stats)
+	finishSyncRun(db, run, &stats)
+
+	fmt.Println("Successfully fetched data and written to the DB")
+	return run, nil
+}
+
+func writePersonRows(db *gorm.DB, dataChannel chan PersonDB, batchSize int, stats *syncStats) {
+	var batch []PersonDB
+	for entry := range dataChannel {
+		batch = append(batch, entry)
+		if len(batch) >= batchSize {
+			if err := writePersonsBatch(db, batch); err != nil {
+				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.ID)
+				}
+			}
+			batch = []PersonDB{}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := writePersonsBatch(db, batch); err != nil {
+			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.ID)
+			}
+		}
+	}
+}
+func writePersonsBatch(db *gorm.DB, objects []PersonDB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{UpdateAll: true}).Table("CinemaPerson").Model(&PersonDB{}).Create(&objects).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PersonCredit struct {
+	ID         uint32     `json:"id"`
+	Title      string     `json:"title"`
+	PosterPath *string    `json:"poster_path" gorm:"column:posterPath"`
+	Role       string     `json:"role"`
+	Date       *time.Time `json:"date"`
+	Upcoming   bool       `json:"upcoming"`
+}
+
+type PersonDetail struct {
+	PersonDB
+	Movies  []PersonCredit `json:"movies"`
+	TVShows []PersonCredit `json:"tv_shows"`
+}
+
+const (
+	personMovieCreditsQuery = `SELECT m.id, m.title, m."posterPath", c.role, m."primaryReleaseDate"::date::timestamp AS date,
+		COALESCE(m."primaryReleaseDate"::date >= CURRENT_DATE, false) AS upcoming
+		FROM (SELECT "movieId", 'actor' AS role FROM "MovieActor" WHERE "actorId" = @id
+			UNION ALL SELECT "movieId", 'director' AS role FROM "MovieDirector" WHERE "directorId" = @id) AS c
+		JOIN "Movie" AS m ON m.id = c."movieId"
+		WHERE m."deletedAt" IS NULL`
+	personTVCreditsQuery = `SELECT s.id, s.name AS title, s."posterPath", c.role,
+		COALESCE(next.date, s."firstAirDate"::date::timestamp) AS date, next.date IS NOT NULL AS upcoming
+		FROM (SELECT "showId", 'actor' AS role FROM "TVShowActor" WHERE "actorId" = @id
+			UNION ALL SELECT "showId", 'creator' AS role FROM "TVShowCreator" WHERE "creatorId" = @id) AS c
+		JOIN "TVShow" AS s ON s.id = c."showId"
+		LEFT JOIN LATERAL (SELECT MIN(se."airDate"::date)::timestamp AS date FROM "TVSeason" AS se
+			WHERE se."showId" = s.id AND se."airDate"::date >= CURRENT_DATE) AS next ON true
+		WHERE s."deletedAt" IS NULL`
+)
+
+// PersonDetails returns a CinemaPerson profile with the movies and TV shows
+// they acted in, directed or created, newest first. With ?upcoming=true only
+// unreleased movies and shows with a season still to air are listed.
+func PersonDetails(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeRead) {
+		return
+	}
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Missing or invalid id", http.StatusBadRequest)
+		return
+	}
+	db, err := openDB()
+	if err != nil {
+		http.Error(w, "Error connecting to the DB", http.StatusInternalServerError)
+		return
+	}
+
+	var people []PersonDB
+	if err := db.Table("CinemaPerson").Where("id = ?", id).Limit(1).Find(&people).Error; err != nil {
+		http.Error(w, "Error reading person", http.StatusInternalServerError)
+		return
+	}
+	if len(people) == 0 {
+		http.Error(w, "Person not found", http.StatusNotFound)
+		return
+	}
+
+	detail, err := loadPersonDetail(db, people[0], r.URL.Query().Get("upcoming") == "true")
+	if err != nil {
+		http.Error(w, "Error reading person credits", http.StatusInternalServerError)
+		return
+	}
+	writeCachedJSON(w, r, detail)
+}
+
+func loadPersonDetail(db *gorm.DB, person PersonDB, upcoming bool) (PersonDetail, error) {
+	person.Birthday = normalizeDatePtr(person.Birthday)
+	detail := PersonDetail{
+		PersonDB: person,
+		Movies:   []PersonCredit{},
+		TVShows:  []PersonCredit{},
+	}
+
+	filter := ""
+	if upcoming {
+		filter = " WHERE upcoming"
+	}
+	order := " ORDER BY date DESC NULLS FIRST, id, role"
+	args := map[string]interface{}{"id": person.ID}
+	if err := db.Raw("SELECT * FROM ("+personMovieCreditsQuery+") AS credits"+filter+order, args).Scan(&detail.Movies).Error; err != nil {
+		return detail, err
+	}
+	if err := db.Raw("SELECT * FROM ("+personTVCreditsQuery+") AS credits"+filter+order, args).Scan(&detail.TVShows).Error; err != nil {
+		return detail, err
+	}
+	if detail.Movies == nil {
+		detail.Movies = []PersonCredit{}
+	}
+	if detail.TVShows == nil {
+		detail.TVShows = []PersonCredit{}
+	}
+	return detail, nil
+}
@@ -0,0 +1,374 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ReleaseDateChange struct {
+	ID           uint32     `json:"id" gorm:"primaryKey;autoIncrement"`
+	EntityType   string     `json:"entity_type" gorm:"column:entityType;index:idx_release_date_history_entity"`
+	EntityId     uint32     `json:"entity_id" gorm:"column:entityId;index:idx_release_date_history_entity"`
+	ReleaseId    uint32     `json:"release_id" gorm:"column:releaseId"`
+	Region       *string    `json:"region"`
+	PlatformId   *uint16    `json:"platform_id" gorm:"column:platformId"`
+	SeasonNumber *uint16    `json:"season_number" gorm:"column:seasonNumber"`
+	OldDate      *time.Time `json:"old_date" gorm:"column:oldDate"`
+	NewDate      *time.Time `json:"new_date" gorm:"column:newDate"`
+	ObservedAt   time.Time  `json:"observed_at" gorm:"column:observedAt;index"`
+	Direction    string     `json:"direction" gorm:"-"`
+}
+
+func ReleaseDateChanges(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeRead) {
+		return
+	}
+	db, err := openDB()
+	if err != nil {
+		http.Error(w, "Error connecting to the DB", http.StatusInternalServerError)
+		return
+	}
+
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	query := db.Table("ReleaseDateHistory").
+		Where(`"observedAt" >= ?`, time.Now().AddDate(0, 0, -days)).
+		Where(`"oldDate" IS NOT NULL AND "newDate" IS NOT NULL`)
+	if entityType := r.URL.Query().Get("type"); entityType != "" {
+		query = query.Where(`"entityType" = ?`, entityType)
+	}
+	switch r.URL.Query().Get("direction") {
+	case "delay":
+		query = query.Where(`"newDate" > "oldDate"`)
+	case "pull_in":
+		query = query.Where(`"newDate" < "oldDate"`)
+	}
+
+	var changes []ReleaseDateChange
+	if err := query.Order(`"observedAt" desc`).Limit(limit).Find(&changes).Error; err != nil {
+		http.Error(w, "Error reading release date changes", http.StatusInternalServerError)
+		return
+	}
+	for i := range changes {
+		changes[i].Direction = releaseDateDirection(changes[i].OldDate, changes[i].NewDate)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changes)
+}
+
+func releaseDateDirection(oldDate, newDate *time.Time) string {
+	switch {
+	case oldDate == nil && newDate != nil:
+		return "dated"
+	case oldDate != nil && newDate == nil:
+		return "undated"
+	case newDate.After(*oldDate):
+		return "delay"
+	default:
+		return "pull_in"
+	}
+}
+
+func sameReleaseDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+func parseAirDate(input *string) *time.Time {
+	if input == nil {
+		return nil
+	}
+	date, err := time.Parse("2006-01-02", *input)
+	if err != nil {
+		return nil
+	}
+	return &date
+}
+
+func recordReleaseDateChanges(tx *gorm.DB, changes []ReleaseDateChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	return tx.Table("ReleaseDateHistory").Create(&changes).Error
+}
+
+func recordGameReleaseDateChanges(tx *gorm.DB, objects []ReleaseDateDB) error {
+	ids := make([]uint32, 0, len(objects))
+	for _, object := range objects {
+		ids = append(ids, object.ID)
+	}
+	var existing []ReleaseDateDB
+	if err := tx.Table("GReleaseDate").Where("id IN ?", ids).Find(&existing).Error; err != nil {
+		return err
+	}
+	existingById := make(map[uint32]ReleaseDateDB, len(existing))
+	for _, row := range existing {
+		existingById[row.ID] = row
+	}
+
+	now := time.Now()
+	var changes []ReleaseDateChange
+	var payloads []WebhookPayload
+	for _, object := range objects {
+		old, ok := existingById[object.ID]
+		region := strconv.Itoa(int(object.Region))
+		platformId := object.PlatformId
+		change := ReleaseDateChange{
+			EntityType: "game",
+			EntityId:   object.GameId,
+			ReleaseId:  object.ID,
+			Region:     &region,
+			PlatformId: &platformId,
+			OldDate:    old.Date,
+			NewDate:    object.Date,
+			ObservedAt: now,
+		}
+		if ok && !sameReleaseDate(old.Date, object.Date) {
+			changes = append(changes, change)
+		}
+
+		// Only exact day dates count as concrete, IGDB keeps placeholder dates for quarters and years.
+		wasConcrete := ok && old.Category == 0 && old.Date != nil
+		isConcrete := object.Category == 0 && object.Date != nil
+		switch {
+		case isConcrete && !wasConcrete:
+			payloads = append(payloads, releaseDatePayload("release_date.dated", change))
+		case isConcrete && !sameReleaseDate(old.Date, object.Date):
+			payloads = append(payloads, releaseDatePayload("release_date.changed", change))
+		}
+	}
+	if err := recordReleaseDateChanges(tx, changes); err != nil {
+		return err
+	}
+	return enqueueWebhookEvents(tx, payloads)
+}
+
+// recordMovieReleaseDateChanges compares the release dates of each movie per
+// country and release type, see movieReleaseDateChanges.
+func recordMovieReleaseDateChanges(tx *gorm.DB, objects []movieReleases, stored []MovieReleaseDB, current []MovieReleaseDB) error {
+	changes, payloads := movieReleaseDateChanges(objects, stored, current, time.Now())
+	if err := recordReleaseDateChanges(tx, changes); err != nil {
+		return err
+	}
+	return enqueueWebhookEvents(tx, payloads)
+}
+
+// movieReleaseDateChanges diffs the stored and incoming dates of every country
+// and release type of the movies in the batch. Removed and added dates are
+// paired in date order as moves, the rest are recorded with a nil OldDate or
+// NewDate. A move is announced as changed, the first date of a type without
+// any as dated. Further dates of a type that already had one, such as a
+// second premiere, and removed dates are recorded but not announced.
+func movieReleaseDateChanges(objects []movieReleases, stored []MovieReleaseDB, current []MovieReleaseDB, now time.Time) ([]ReleaseDateChange, []WebhookPayload) {
+	type releaseGroup struct {
+		MovieId  uint32
+		ISO31661 string
+		Type     uint8
+	}
+	storedRows := map[releaseGroup][]MovieReleaseDB{}
+	var storedGroups []releaseGroup
+	for _, row := range stored {
+		group := releaseGroup{row.MovieId, row.ISO31661, row.Type}
+		if _, ok := storedRows[group]; !ok {
+			storedGroups = append(storedGroups, group)
+		}
+		storedRows[group] = append(storedRows[group], row)
+	}
+	currentIds := map[movieReleaseKey]uint32{}
+	for _, row := range current {
+		currentIds[row.key()] = row.ID
+	}
+
+	var groups []releaseGroup
+	incoming := map[releaseGroup][]time.Time{}
+	for _, object := range objects {
+		for _, release := range object.Releases {
+			group := releaseGroup{object.MovieId, release.ISO31661, release.Type}
+			if _, ok := incoming[group]; !ok {
+				groups = append(groups, group)
+			}
+			incoming[group] = append(incoming[group], release.ReleaseDate)
+		}
+	}
+	// Groups no longer listed lost all their dates.
+	for _, group := range storedGroups {
+		if _, ok := incoming[group]; !ok {
+			groups = append(groups, group)
+		}
+	}
+
+	var changes []ReleaseDateChange
+	var payloads []WebhookPayload
+	for _, group := range groups {
+		incomingDates := map[int64]bool{}
+		for _, date := range incoming[group] {
+			incomingDates[date.UnixMilli()] = true
+		}
+		storedDates := map[int64]bool{}
+		var removed []MovieReleaseDB
+		for _, row := range storedRows[group] {
+			storedDates[row.ReleaseDate.UnixMilli()] = true
+			if !incomingDates[row.ReleaseDate.UnixMilli()] {
+				removed = append(removed, row)
+			}
+		}
+		var added []time.Time
+		for _, date := range incoming[group] {
+			if !storedDates[date.UnixMilli()] {
+				storedDates[date.UnixMilli()] = true
+				added = append(added, date)
+			}
+		}
+		sort.Slice(removed, func(i, j int) bool { return removed[i].ReleaseDate.Before(removed[j].ReleaseDate) })
+		sort.Slice(added, func(i, j int) bool { return added[i].Before(added[j]) })
+
+		for i := 0; i < len(removed) || i < len(added); i++ {
+			region := group.ISO31661
+			change := ReleaseDateChange{
+				EntityType: "movie",
+				EntityId:   group.MovieId,
+				Region:     &region,
+				ObservedAt: now,
+			}
+			if i < len(removed) {
+				oldDate := removed[i].ReleaseDate
+				change.ReleaseId = removed[i].ID
+				change.OldDate = &oldDate
+			}
+			if i < len(added) {
+				newDate := added[i]
+				change.ReleaseId = currentIds[movieReleaseKey{group.MovieId, group.ISO31661, group.Type, newDate.UnixMilli()}]
+				change.NewDate = &newDate
+			}
+			changes = append(changes, change)
+
+			switch {
+			case change.OldDate != nil && change.NewDate != nil:
+				payloads = append(payloads, releaseDatePayload("release_date.changed", change))
+			case change.NewDate != nil && len(storedRows[group]) == 0:
+				payloads = append(payloads, releaseDatePayload("release_date.dated", change))
+			}
+		}
+	}
+	return changes, payloads
+}
+
+// primaryReleaseDateChanges records the movies whose primary release date
+// moved, appeared or was removed. The rows carry no region or release ID.
+// Movies new to the table have no stored date to compare.
+func primaryReleaseDateChanges(objects []MovieDB, existingById map[uint32]*time.Time, now time.Time) []ReleaseDateChange {
+	var changes []ReleaseDateChange
+	for _, object := range objects {
+		oldDate, ok := existingById[object.ID]
+		newDate := parseAirDate(object.ReleaseDateStr)
+		if !ok || sameReleaseDate(oldDate, newDate) {
+			continue
+		}
+		changes = append(changes, ReleaseDateChange{
+			EntityType: "movie",
+			EntityId:   object.ID,
+			OldDate:    oldDate,
+			NewDate:    newDate,
+			ObservedAt: now,
+		})
+	}
+	return changes
+}
+
+func recordPrimaryReleaseDateChanges(tx *gorm.DB, objects []MovieDB) error {
+	ids := make([]uint32, 0, len(objects))
+	for _, object := range objects {
+		ids = append(ids, object.ID)
+	}
+	var existing []struct {
+		ID          uint32
+		ReleaseDate *time.Time `gorm:"column:releaseDate"`
+	}
+	err := tx.Table("Movie").
+		Select(`id, "primaryReleaseDate"::date AS "releaseDate"`).
+		Where("id IN ?", ids).
+		Scan(&existing).Error
+	if err != nil {
+		return err
+	}
+	existingById := make(map[uint32]*time.Time, len(existing))
+	for _, row := range existing {
+		existingById[row.ID] = row.ReleaseDate
+	}
+	return recordReleaseDateChanges(tx, primaryReleaseDateChanges(objects, existingById, time.Now()))
+}
+
+func recordSeasonAirDateChanges(tx *gorm.DB, objects []TVSeasonDB) error {
+	ids := make([]uint32, 0, len(objects))
+	for _, object := range objects {
+		ids = append(ids, object.ID)
+	}
+	var existing []struct {
+		ID      uint32
+		AirDate *time.Time `gorm:"column:airDate"`
+	}
+	err := tx.Table("TVSeason").
+		Select(`id, "airDate"::date AS "airDate"`).
+		Where("id IN ?", ids).
+		Scan(&existing).Error
+	if err != nil {
+		return err
+	}
+	existingById := make(map[uint32]*time.Time, len(existing))
+	for _, row := range existing {
+		existingById[row.ID] = row.AirDate
+	}
+
+	changes, payloads := seasonAirDateChanges(objects, existingById, time.Now())
+	if err := recordReleaseDateChanges(tx, changes); err != nil {
+		return err
+	}
+	return enqueueWebhookEvents(tx, payloads)
+}
+
+// seasonAirDateChanges compares incoming seasons with the stored air dates,
+// keyed by TMDB season ID, and returns the history rows and webhook payloads.
+func seasonAirDateChanges(objects []TVSeasonDB, existingById map[uint32]*time.Time, now time.Time) ([]ReleaseDateChange, []WebhookPayload) {
+	var changes []ReleaseDateChange
+	var payloads []WebhookPayload
+	for _, object := range objects {
+		oldDate, ok := existingById[object.ID]
+		newDate := parseAirDate(object.AirDate)
+		seasonNumber := object.SeasonNumber
+		change := ReleaseDateChange{
+			EntityType:   "tv",
+			EntityId:     object.ShowID,
+			ReleaseId:    object.ID,
+			SeasonNumber: &seasonNumber,
+			OldDate:      oldDate,
+			NewDate:      newDate,
+			ObservedAt:   now,
+		}
+		if ok && !sameReleaseDate(oldDate, newDate) {
+			changes = append(changes, change)
+		}
+
+		switch {
+		case newDate != nil && oldDate == nil:
+			payloads = append(payloads, releaseDatePayload("release_date.dated", change))
+		case newDate != nil && !sameReleaseDate(oldDate, newDate):
+			payloads = append(payloads, releaseDatePayload("release_date.changed", change))
+		}
+	}
+	return changes, payloads
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMovieReleaseDateChangesEvents(t *testing.T) {
+	day := func(date string) time.Time {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	stored := func(id uint32, iso string, releaseType uint8, date string) MovieReleaseDB {
+		return MovieReleaseDB{MovieId: 550, ID: id, ISO31661: iso, Type: releaseType, ReleaseDate: day(date)}
+	}
+	incoming := func(iso string, releaseType uint8, date string) MLocalRelease {
+		return MLocalRelease{ISO31661: iso, Type: releaseType, ReleaseDate: day(date)}
+	}
+
+	tests := []struct {
+		name       string
+		stored     []MovieReleaseDB
+		releases   []MLocalRelease
+		wantEvents []string
+		wantRows   []string
+	}{
+		{
+			name:     "unchanged dates",
+			stored:   []MovieReleaseDB{stored(1, "US", 3, "2026-11-20"), stored(2, "DE", 3, "2026-11-27")},
+			releases: []MLocalRelease{incoming("DE", 3, "2026-11-27"), incoming("US", 3, "2026-11-20")},
+		},
+		{
+			name:       "delayed theatrical date",
+			stored:     []MovieReleaseDB{stored(1, "US", 3, "2026-11-20")},
+			releases:   []MLocalRelease{incoming("US", 3, "2026-12-18")},
+			wantEvents: []string{"release_date.changed"},
+			wantRows:   []string{"US 2026-11-20 -> 2026-12-18"},
+		},
+		{
+			name:       "first date for a country and type",
+			stored:     []MovieReleaseDB{stored(1, "US", 3, "2026-11-20")},
+			releases:   []MLocalRelease{incoming("US", 3, "2026-11-20"), incoming("US", 4, "2027-02-01")},
+			wantEvents: []string{"release_date.dated"},
+			wantRows:   []string{"US none -> 2027-02-01"},
+		},
+		{
+			name:       "first dates of a new movie",
+			releases:   []MLocalRelease{incoming("US", 3, "2026-11-20"), incoming("DE", 3, "2026-11-27")},
+			wantEvents: []string{"release_date.dated", "release_date.dated"},
+			wantRows:   []string{"US none -> 2026-11-20", "DE none -> 2026-11-27"},
+		},
+		{
+			name:     "second premiere of a type already dated",
+			stored:   []MovieReleaseDB{stored(1, "US", 1, "2026-09-01")},
+			releases: []MLocalRelease{incoming("US", 1, "2026-09-01"), incoming("US", 1, "2026-10-05")},
+			wantRows: []string{"US none -> 2026-10-05"},
+		},
+		{
+			name:     "removed date",
+			stored:   []MovieReleaseDB{stored(1, "US", 1, "2026-09-01"), stored(2, "US", 1, "2026-10-05")},
+			releases: []MLocalRelease{incoming("US", 1, "2026-09-01")},
+			wantRows: []string{"US 2026-10-05 -> none"},
+		},
+		{
+			name:     "country no longer listed",
+			stored:   []MovieReleaseDB{stored(1, "US", 3, "2026-11-20"), stored(2, "DE", 3, "2026-11-27")},
+			releases: []MLocalRelease{incoming("US", 3, "2026-11-20")},
+			wantRows: []string{"DE 2026-11-27 -> none"},
+		},
+		{
+			name:       "moved date in a type with several dates",
+			stored:     []MovieReleaseDB{stored(1, "US", 3, "2026-11-20"), stored(2, "US", 3, "2026-12-01")},
+			releases:   []MLocalRelease{incoming("US", 3, "2026-11-20"), incoming("US", 3, "2027-01-15")},
+			wantEvents: []string{"release_date.changed"},
+			wantRows:   []string{"US 2026-12-01 -> 2027-01-15"},
+		},
+		{
+			name:       "every date of a type moved",
+			stored:     []MovieReleaseDB{stored(1, "GB", 3, "2026-11-20"), stored(2, "GB", 3, "2026-12-01")},
+			releases:   []MLocalRelease{incoming("GB", 3, "2027-01-15"), incoming("GB", 3, "2026-11-27")},
+			wantEvents: []string{"release_date.changed", "release_date.changed"},
+			wantRows:   []string{"GB 2026-11-20 -> 2026-11-27", "GB 2026-12-01 -> 2027-01-15"},
+		},
+		{
+			name:     "reordered list keeps every date",
+			stored:   []MovieReleaseDB{stored(1, "FR", 3, "2026-11-25"), stored(2, "GB", 3, "2026-11-21"), stored(3, "US", 3, "2026-11-20")},
+			releases: []MLocalRelease{incoming("US", 3, "2026-11-20"), incoming("GB", 3, "2026-11-21"), incoming("FR", 3, "2026-11-25")},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := []movieReleases{{MovieId: 550, Releases: test.releases}}
+			changes, payloads := movieReleaseDateChanges(objects, test.stored, test.stored, time.Now())
+			var rows []string
+			for _, change := range changes {
+				rows = append(rows, fmt.Sprintf("%s %s -> %s", *change.Region, formatDay(change.OldDate), formatDay(change.NewDate)))
+			}
+			if !sameStrings(rows, test.wantRows) {
+				t.Errorf("history rows = %v, want %v", rows, test.wantRows)
+			}
+			var events []string
+			for _, payload := range payloads {
+				events = append(events, payload.Event)
+			}
+			if len(events) != len(test.wantEvents) {
+				t.Fatalf("events = %v, want %v", events, test.wantEvents)
+			}
+			for i := range events {
+				if events[i] != test.wantEvents[i] {
+					t.Errorf("events = %v, want %v", events, test.wantEvents)
+				}
+			}
+		})
+	}
+}
+
+func TestPrimaryReleaseDateChanges(t *testing.T) {
+	date := func(value string) *string {
+		return &value
+	}
+	objects := []MovieDB{
+		{ID: 1, ReleaseDateStr: date("2026-11-20")},
+		{ID: 2, ReleaseDateStr: date("2026-12-18")},
+		{ID: 3, ReleaseDateStr: date("2027-03-05")},
+		{ID: 4},
+		{ID: 5, ReleaseDateStr: date("2027-05-01")},
+	}
+	existing := map[uint32]*time.Time{
+		1: parseAirDate(date("2026-11-20")),
+		2: parseAirDate(date("2026-11-20")),
+		3: nil,
+		4: parseAirDate(date("2026-10-01")),
+	}
+	var rows []string
+	for _, change := range primaryReleaseDateChanges(objects, existing, time.Now()) {
+		if change.Region != nil || change.ReleaseId != 0 {
+			t.Errorf("got %+v, want no region or release ID", change)
+		}
+		rows = append(rows, fmt.Sprintf("%d %s -> %s", change.EntityId, formatDay(change.OldDate), formatDay(change.NewDate)))
+	}
+	want := []string{"2 2026-11-20 -> 2026-12-18", "3 none -> 2027-03-05", "4 2026-10-01 -> none"}
+	if !sameStrings(rows, want) {
+		t.Errorf("history rows = %v, want %v", rows, want)
+	}
+}
+
+func formatDay(date *time.Time) string {
+	if date == nil {
+		return "none"
+	}
+	return date.Format("2006-01-02")
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CalendarRelease struct {
+	Type         string    `json:"type"`
+	ID           uint32    `json:"id"`
+	ReleaseId    uint32    `json:"release_id" gorm:"column:releaseId"`
+	Title        string    `json:"title"`
+	Slug         *string   `json:"slug"`
+	Date         time.Time `json:"date"`
+	DateLabel    *string   `json:"date_label" gorm:"column:dateLabel"`
+	PlatformId   *uint16   `json:"platform_id" gorm:"column:platformId"`
+	Region       *string   `json:"region"`
+	ReleaseType  *uint8    `json:"release_type" gorm:"column:releaseType"`
+	SeasonNumber *uint16   `json:"season_number" gorm:"column:seasonNumber"`
+}
+
+type CalendarPage struct {
+	From    string            `json:"from"`
+	To      string            `json:"to"`
+	Page    int               `json:"page"`
+	Limit   int               `json:"limit"`
+	HasMore bool              `json:"has_more"`
+	Results []CalendarRelease `json:"results"`
+}
+
+const (
+	gameCalendarQuery = `SELECT 'game' AS type, g.id, r.id AS "releaseId", g.name AS title, g.slug, r.date,
+		r.human AS "dateLabel", r."platformId", r.region::text AS region, NULL::smallint AS "releaseType", NULL::integer AS "seasonNumber"
+		FROM "GReleaseDate" AS r JOIN "Game" AS g ON g.id = r."gameId"
+		WHERE g."deletedAt" IS NULL AND r.date >= ? AND r.date < ?`
+	movieCalendarQuery = `SELECT 'movie' AS type, m.id, l.id AS "releaseId", m.title, NULL AS slug, l."releaseDate" AS date,
+		l.note AS "dateLabel", NULL::integer AS "platformId", c.iso31661 AS region, l.type AS "releaseType", NULL::integer AS "seasonNumber"
+		FROM "MLocalRelease" AS l JOIN "MReleaseCountry" AS c ON c.id = l."releaseCountryId" JOIN "Movie" AS m ON m.id = c."movieId"
+		WHERE m."deletedAt" IS NULL AND l."releaseDate" >= ? AND l."releaseDate" < ?`
+	tvCalendarQuery = `SELECT 'tv' AS type, s.id, se.id AS "releaseId", s.name AS title, NULL AS slug, se."airDate"::date::timestamp AS date,
+		se.name AS "dateLabel", NULL::integer AS "platformId", NULL AS region, NULL::smallint AS "releaseType", se."seasonNumber"
+		FROM "TVSeason" AS se JOIN "TVShow" AS s ON s.id = se."showId"
+		WHERE s."deletedAt" IS NULL AND se."airDate"::date >= ? AND se."airDate"::date < ?`
+)
+
+// Releases returns a paginated calendar of game, movie and TV season releases
+// between from and to (inclusive, YYYY-MM-DD), optionally narrowed by type,
+// IGDB platform ID and region (IGDB region ID or ISO 3166-1 country).
+func Releases(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeRead) {
+		return
+	}
+	query := r.URL.Query()
+	from, err := time.Parse("2006-01-02", query.Get("from"))
+	if err != nil {
+		from = time.Now().Truncate(24 * time.Hour)
+	}
+	to, err := time.Parse("2006-01-02", query.Get("to"))
+	if err != nil {
+		to = from.AddDate(0, 0, 90)
+	}
+	if to.Before(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	mediaType := query.Get("type")
+	if mediaType != "" && mediaType != "game" && mediaType != "movie" && mediaType != "tv" {
+		http.Error(w, "type must be one of game, movie or tv", http.StatusBadRequest)
+		return
+	}
+	platform := query.Get("platform")
+	region := query.Get("region")
+
+	end := to.AddDate(0, 0, 1)
+	var parts []string
+	var args []interface{}
+	if mediaType == "" || mediaType == "game" {
+		part := gameCalendarQuery
+		args = append(args, from, end)
+		if platform != "" {
+			part += ` AND r."platformId"::text = ?`
+			args = append(args, platform)
+		}
+		if region != "" {
+			part += ` AND r.region::text = ?`
+			args = append(args, region)
+		}
+		parts = append(parts, part)
+	}
+	if (mediaType == "" || mediaType == "movie") && platform == "" {
+		part := movieCalendarQuery
+		args = append(args, from, end)
+		if region != "" {
+			part += ` AND c.iso31661 = ?`
+			args = append(args, strings.ToUpper(region))
+		}
+		parts = append(parts, part)
+	}
+	if (mediaType == "" || mediaType == "tv") && platform == "" {
+		part := tvCalendarQuery
+		args = append(args, from, end)
+		if region != "" {
+			part += ` AND EXISTS (SELECT 1 FROM "TVShowOrigCountry" AS oc WHERE oc."showId" = s.id AND oc."countryIso" = ?)`
+			args = append(args, strings.ToUpper(region))
+		}
+		parts = append(parts, part)
+	}
+
+	result := CalendarPage{
+		From:    from.Format("2006-01-02"),
+		To:      to.Format("2006-01-02"),
+		Page:    page,
+		Limit:   limit,
+		Results: []CalendarRelease{},
+	}
+	if len(parts) > 0 {
+		db, err := openDB()
+		if err != nil {
+			http.Error(w, "Error connecting to the DB", http.StatusInternalServerError)
+			return
+		}
+
+		sql := "SELECT * FROM (" + strings.Join(parts, " UNION ALL ") + `) AS releases
+			ORDER BY date, type, id, "releaseId" LIMIT ? OFFSET ?`
+		args = append(args, limit+1, (page-1)*limit)
+		if err := db.Raw(sql, args...).Scan(&result.Results).Error; err != nil {
+			http.Error(w, "Error reading releases", http.StatusInternalServerError)
+			return
+		}
+		if result.Results == nil {
+			result.Results = []CalendarRelease{}
+		}
+		if len(result.Results) > limit {
+			result.HasMore = true
+			result.Results = result.Results[:limit]
+		}
+	}
+
+	writeCachedJSON(w, r, result)
+}
+
+// writeCachedJSON writes data with an ETag. Responses may only sit in shared
+// caches while read handlers are public; once READ_API_TOKEN is set a CDN would
+// otherwise serve an authorized response to anyone.
+func writeCachedJSON(w http.ResponseWriter, r *http.Request, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+	etag := `"` + contentHash(json.RawMessage(body)) + `"`
+
+	w.Header().Set("ETag", etag)
+	if os.Getenv("READ_API_TOKEN") == "" {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+	} else {
+		w.Header().Set("Cache-Control", "private, max-age=300")
+	}
+	w.Header().Add("Vary", "Authorization")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
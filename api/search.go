@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type SearchResult struct {
+	Type        string  `json:"type"`
+	ID          uint32  `json:"id"`
+	Title       string  `json:"title"`
+	Slug        *string `json:"slug"`
+	MatchedName string  `json:"matched_name" gorm:"column:matchedName"`
+	Score       float32 `json:"score"`
+	Popularity  float32 `json:"popularity"`
+}
+
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+}
+
+var (
+	searchIndexes = []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS "Game_name_trgm_idx" ON "Game" USING gin (name gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS "GAltName_name_trgm_idx" ON "GAltName" USING gin (name gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS "GLocalization_name_trgm_idx" ON "GLocalization" USING gin (name gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS "Movie_title_trgm_idx" ON "Movie" USING gin (title gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS "Movie_originaltitle_trgm_idx" ON "Movie" USING gin (originaltitle gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS "TVShow_name_trgm_idx" ON "TVShow" USING gin (name gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS "TVShow_originalName_trgm_idx" ON "TVShow" USING gin ("originalName" gin_trgm_ops)`,
+	}
+)
+
+// Names are matched by trigram similarity or substring, both served by the
+// trigram indexes above. Substring and exact matches get a floor score so short
+// queries still rank, and Search adds a log-scaled popularity boost on top.
+func searchScoreExpr(column string) string {
+	return fmt.Sprintf(`GREATEST(similarity(%[1]s, @q), CASE WHEN %[1]s ILIKE @pattern THEN 0.5 ELSE 0 END, CASE WHEN lower(%[1]s) = lower(@q) THEN 1 ELSE 0 END)`, column)
+}
+
+func searchMatchExpr(column string) string {
+	return fmt.Sprintf(`(%[1]s %% @q OR %[1]s ILIKE @pattern)`, column)
+}
+
+func gameSearchQuery() string {
+	return fmt.Sprintf(`SELECT DISTINCT ON (g.id) 'game' AS type, g.id, g.name AS title, g.slug, m.name AS "matchedName", m.score,
+		(COALESCE(g.hypes, 0) + COALESCE(g.follows, 0))::real AS popularity
+		FROM (
+			SELECT id AS "gameId", name, %[1]s AS score FROM "Game" WHERE %[2]s
+			UNION ALL SELECT "gameId", name, %[1]s FROM "GAltName" WHERE %[2]s
+			UNION ALL SELECT "gameId", name, %[1]s FROM "GLocalization" WHERE %[2]s
+		) AS m JOIN "Game" AS g ON g.id = m."gameId"
+		WHERE g."deletedAt" IS NULL
+		ORDER BY g.id, m.score DESC`, searchScoreExpr("name"), searchMatchExpr("name"))
+}
+
+func movieSearchQuery() string {
+	return fmt.Sprintf(`SELECT DISTINCT ON (id) 'movie' AS type, id, title, NULL AS slug, matched AS "matchedName", score, popularity
+		FROM (
+			SELECT id, title, title AS matched, %[1]s AS score, popularity FROM "Movie" WHERE "deletedAt" IS NULL AND %[2]s
+			UNION ALL SELECT id, title, originaltitle, %[3]s, popularity FROM "Movie" WHERE "deletedAt" IS NULL AND %[4]s
+		) AS m
+		ORDER BY id, score DESC`, searchScoreExpr("title"), searchMatchExpr("title"), searchScoreExpr("originaltitle"), searchMatchExpr("originaltitle"))
+}
+
+func tvSearchQuery() string {
+	return fmt.Sprintf(`SELECT DISTINCT ON (id) 'tv' AS type, id, name AS title, NULL AS slug, matched AS "matchedName", score, popularity
+		FROM (
+			SELECT id, name, name AS matched, %[1]s AS score, popularity FROM "TVShow" WHERE "deletedAt" IS NULL AND %[2]s
+			UNION ALL SELECT id, name, "originalName", %[3]s, popularity FROM "TVShow" WHERE "deletedAt" IS NULL AND %[4]s
+		) AS m
+		ORDER BY id, score DESC`, searchScoreExpr("name"), searchMatchExpr("name"), searchScoreExpr(`"originalName"`), searchMatchExpr(`"originalName"`))
+}
+
+// Search finds games, movies and TV shows by title, including alternative and
+// localized game names, ranked by match quality and popularity.
+func Search(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeRead) {
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "Missing q", http.StatusBadRequest)
+		return
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 50 {
+		limit = 20
+	}
+	mediaType := r.URL.Query().Get("type")
+
+	var parts []string
+	if mediaType == "" || mediaType == "game" {
+		parts = append(parts, gameSearchQuery())
+	}
+	if mediaType == "" || mediaType == "movie" {
+		parts = append(parts, movieSearchQuery())
+	}
+	if mediaType == "" || mediaType == "tv" {
+		parts = append(parts, tvSearchQuery())
+	}
+	if len(parts) == 0 {
+		http.Error(w, "type must be one of game, movie or tv", http.StatusBadRequest)
+		return
+	}
+
+	db, err := openDB()
+	if err != nil {
+		http.Error(w, "Error connecting to the DB", http.StatusInternalServerError)
+		return
+	}
+	// The step is version-gated, so this is one lookup once the indexes exist.
+	if err := migrateSearchSchema(db); err != nil {
+		fmt.Println("Error migrating search indexes:", err)
+		http.Error(w, "Search indexes are not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	response := SearchResponse{Query: q}
+	query := "SELECT * FROM ((" + strings.Join(parts, ") UNION ALL (") + `)) AS results
+		ORDER BY score + 0.05 * ln(1 + popularity) DESC, id LIMIT @limit`
+	err = db.Raw(query,
+		sql.Named("q", q),
+		sql.Named("pattern", "%"+escapeLikePattern(q)+"%"),
+		sql.Named("limit", limit),
+	).Scan(&response.Results).Error
+	if err != nil {
+		http.Error(w, "Error searching", http.StatusInternalServerError)
+		return
+	}
+	if response.Results == nil {
+		response.Results = []SearchResult{}
+	}
+
+	writeCachedJSON(w, r, response)
+}
+
+func escapeLikePattern(input string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(input)
+}
+
+func migrateSearchIndexes(db *gorm.DB) error {
+	for _, statement := range searchIndexes {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// SyncOutboxEvent is one change event. Position is assigned by
+// publishSyncOutbox once the transaction that inserted the event is over, so
+// it follows commit order where the ID follows insert order.
+type SyncOutboxEvent struct {
+	ID         uint64         `json:"id" gorm:"primaryKey;autoIncrement"`
+	Position   uint64         `json:"position" gorm:"->;-:migration"`
+	EntityType string         `json:"entity_type" gorm:"column:entityType"`
+	EntityId   uint32         `json:"entity_id" gorm:"column:entityId"`
+	Action     string         `json:"action"`
+	Fields     pq.StringArray `json:"fields" gorm:"type:text[]"`
+	CreatedAt  time.Time      `json:"created_at" gorm:"column:createdAt"`
+}
+
+type SyncOutboxCursor struct {
+	Consumer  string    `gorm:"primaryKey"`
+	Cursor    uint64    `gorm:"column:cursor"`
+	UpdatedAt time.Time `gorm:"column:updatedAt"`
+}
+
+type SyncOutboxPage struct {
+	Events     []SyncOutboxEvent `json:"events"`
+	NextCursor uint64            `json:"next_cursor"`
+}
+
+var (
+	outboxSchemaCache = &sync.Map{}
+)
+
+// SyncOutbox pages through change events with GET ?consumer=&after=&limit= and
+// acknowledges them with POST ?consumer=&cursor=. Without after, a consumer
+// resumes from its last acknowledged cursor.
+func SyncOutbox(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeSync) {
+		return
+	}
+	db, err := openDB()
+	if err != nil {
+		http.Error(w, "Error connecting to the DB", http.StatusInternalServerError)
+		return
+	}
+
+	consumer := r.URL.Query().Get("consumer")
+	if consumer == "" {
+		http.Error(w, "Missing consumer", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		readSyncOutbox(db, w, r, consumer)
+	case http.MethodPost:
+		ackSyncOutbox(db, w, r, consumer)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func readSyncOutbox(db *gorm.DB, w http.ResponseWriter, r *http.Request, consumer string) {
+	after, err := strconv.ParseUint(r.URL.Query().Get("after"), 10, 64)
+	if err != nil {
+		var cursor SyncOutboxCursor
+		db.Table("SyncOutboxCursor").Where("consumer = ?", consumer).Limit(1).Find(&cursor)
+		after = cursor.Cursor
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	if err := publishSyncOutbox(db); err != nil {
+		http.Error(w, "Error publishing sync outbox", http.StatusInternalServerError)
+		return
+	}
+	page := SyncOutboxPage{NextCursor: after}
+	if err := db.Table("SyncOutbox").Where("position > ?", after).Order("position").Limit(limit).Find(&page.Events).Error; err != nil {
+		http.Error(w, "Error reading sync outbox", http.StatusInternalServerError)
+		return
+	}
+	if len(page.Events) > 0 {
+		page.NextCursor = page.Events[len(page.Events)-1].Position
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// publishSyncOutbox numbers the events whose inserting transaction is over.
+// Autoincrement IDs are taken at insert time, so a later ID can commit first
+// and paging on them skips the earlier event for good. Every transaction still
+// running has an xid of at least the snapshot xmin, so numbering only events
+// below it, under a lock, keeps positions in commit order.
+func publishSyncOutbox(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('SyncOutbox'))`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE "SyncOutbox" AS o SET position = p.position
+			FROM (SELECT id, nextval('"SyncOutbox_position_seq"') AS position FROM (
+				SELECT id FROM "SyncOutbox" WHERE position IS NULL AND "txId" < pg_snapshot_xmin(pg_current_snapshot())
+				ORDER BY "txId", id) AS pending) AS p
+			WHERE o.id = p.id`).Error
+	})
+}
+
+func ackSyncOutbox(db *gorm.DB, w http.ResponseWriter, r *http.Request, consumer string) {
+	cursor, err := strconv.ParseUint(r.URL.Query().Get("cursor"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+
+	ack := SyncOutboxCursor{
+		Consumer:  consumer,
+		Cursor:    cursor,
+		UpdatedAt: time.Now(),
+	}
+	err = db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "consumer"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"cursor":    gorm.Expr(`GREATEST("SyncOutboxCursor"."cursor", EXCLUDED."cursor")`),
+			"updatedAt": ack.UpdatedAt,
+		}),
+	}).Table("SyncOutboxCursor").Create(&ack).Error
+	if err != nil {
+		http.Error(w, "Error acknowledging sync outbox", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "Acknowledged %s up to %d", consumer, cursor)
+}
+
+// recordOutboxEvents compares a batch of base rows with the stored rows and
+// inserts an outbox event listing the changed columns of every new or changed
+// entity. It has to run inside the write transaction, before the upsert.
+func recordOutboxEvents(tx *gorm.DB, table string, entityType string, objects interface{}) error {
+	rows := reflect.ValueOf(objects)
+	if rows.Len() == 0 {
+		return nil
+	}
+	rowSchema, err := schema.Parse(reflect.New(rows.Type().Elem()).Interface(), outboxSchemaCache, tx.NamingStrategy)
+	if err != nil {
+		return err
+	}
+	idField := rowSchema.LookUpField("ID")
+
+	ids := make([]interface{}, 0, rows.Len())
+	for i := 0; i < rows.Len(); i++ {
+		id, _ := idField.ValueOf(tx.Statement.Context, rows.Index(i))
+		ids = append(ids, id)
+	}
+	existing := reflect.New(rows.Type())
+	if err := tx.Table(table).Where("id IN ?", ids).Find(existing.Interface()).Error; err != nil {
+		return err
+	}
+	existingById := make(map[interface{}]reflect.Value, existing.Elem().Len())
+	for i := 0; i < existing.Elem().Len(); i++ {
+		row := existing.Elem().Index(i)
+		id, _ := idField.ValueOf(tx.Statement.Context, row)
+		existingById[id] = row
+	}
+
+	now := time.Now()
+	var events []SyncOutboxEvent
+	for i := 0; i < rows.Len(); i++ {
+		row := rows.Index(i)
+		id, _ := idField.ValueOf(tx.Statement.Context, row)
+		old, exists := existingById[id]
+
+		var fields []string
+		for _, field := range rowSchema.Fields {
+			if field.DBName == "" || field.PrimaryKey || field.AutoUpdateTime > 0 || field.AutoCreateTime > 0 {
+				continue
+			}
+			value, _ := field.ValueOf(tx.Statement.Context, row)
+			if exists {
+				oldValue, _ := field.ValueOf(tx.Statement.Context, old)
+				if sameColumnValue(oldValue, value) {
+					continue
+				}
+			}
+			fields = append(fields, field.DBName)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		action := "updated"
+		if !exists {
+			action = "created"
+		}
+		events = append(events, SyncOutboxEvent{
+			EntityType: entityType,
+			EntityId:   uint32(reflect.ValueOf(id).Uint()),
+			Action:     action,
+			Fields:     fields,
+			CreatedAt:  now,
+		})
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return tx.Table("SyncOutbox").Create(&events).Error
+}
+
+func sameColumnValue(a, b interface{}) bool {
+	av := reflect.ValueOf(a)
+	bv := reflect.ValueOf(b)
+	for av.Kind() == reflect.Pointer {
+		if av.IsNil() {
+			break
+		}
+		av = av.Elem()
+	}
+	for bv.Kind() == reflect.Pointer {
+		if bv.IsNil() {
+			break
+		}
+		bv = bv.Elem()
+	}
+	if av.Kind() == reflect.Pointer || bv.Kind() == reflect.Pointer {
+		return av.Kind() == bv.Kind() && av.IsNil() && bv.IsNil()
+	}
+	if at, ok := av.Interface().(time.Time); ok {
+		bt, ok := bv.Interface().(time.Time)
+		return ok && at.Equal(bt)
+	}
+	if av.Kind() == reflect.Slice && bv.Kind() == reflect.Slice && av.Len() == 0 && bv.Len() == 0 {
+		return true
+	}
+	if av.Kind() == reflect.String && bv.Kind() == reflect.String {
+		// Date columns mapped to strings come back from the DB as RFC 3339 timestamps.
+		return normalizeDateString(av.String()) == normalizeDateString(bv.String())
+	}
+	return reflect.DeepEqual(av.Interface(), bv.Interface())
+}
+
+func normalizeDateString(input string) string {
+	if date, err := time.Parse(time.RFC3339, input); err == nil {
+		return date.Format("2006-01-02")
+	}
+	return input
+}
@@ -0,0 +1,849 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SyncRun struct {
+	ID         uint32     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Kind       string     `json:"kind"`
+	StartedAt  time.Time  `json:"started_at" gorm:"column:startedAt"`
+	FinishedAt *time.Time `json:"finished_at" gorm:"column:finishedAt"`
+	Skipped    uint32     `json:"skipped"`
+	Deleted    uint32     `json:"deleted"`
+	Filtered   uint32     `json:"filtered"`
+	Failed     uint32     `json:"failed"`
+	Partial    bool       `json:"partial"`
+	ResumeFrom *string    `json:"resume_from" gorm:"column:resumeFrom"`
+
+	deadline      time.Time
+	checkpoint    *SyncCheckpoint
+	stopHeartbeat chan struct{}
+}
+
+// SyncCheckpoint holds where a partial run stopped: the next IGDB page for
+// games and the updated_at and ID of the game before it, the last IGDB ID
+// checked by the ID scan, or the TMDB IDs that were not fetched yet for movies,
+// TV and people, plus the movie collections.
+type SyncCheckpoint struct {
+	Kind                 string        `gorm:"primaryKey"`
+	Page                 uint16        `gorm:"column:page"`
+	BeforeUpdatedAt      uint32        `gorm:"column:beforeUpdatedAt"`
+	BeforeId             uint32        `gorm:"column:beforeId"`
+	AfterId              uint64        `gorm:"column:afterId"`
+	PendingIds           pq.Int64Array `gorm:"type:bigint[];column:pendingIds"`
+	PendingCollectionIds pq.Int64Array `gorm:"type:bigint[];column:pendingCollectionIds"`
+	UpdatedAt            time.Time     `gorm:"column:updatedAt"`
+}
+
+// SyncLease marks the run that currently owns a sync kind. The owner refreshes
+// HeartbeatAt while it runs, so a lease left behind by a crashed or timed out
+// run expires after syncLeaseTTL.
+type SyncLease struct {
+	Kind        string    `gorm:"primaryKey"`
+	RunId       uint32    `gorm:"column:runId"`
+	HeartbeatAt time.Time `gorm:"column:heartbeatAt"`
+}
+
+type syncRunningError struct {
+	Kind  string
+	RunId uint32
+}
+
+type syncStats struct {
+	skipped atomic.Uint32
+	invalid atomic.Uint32
+
+	mu          sync.Mutex
+	deletedIds  []uint32
+	filteredIds map[string][]uint32
+	failedIds   map[uint32]bool
+	refsFailed  bool
+}
+
+const (
+	syncLeaseTTL       = 2 * time.Minute
+	syncLeaseHeartbeat = 30 * time.Second
+	// Fetching stops after this budget, leaving the rest of the 300s function
+	// limit for flushing the batches already fetched.
+	defaultSyncTimeBudget = 180 * time.Second
+	// Versions of the schema steps run by migrateSchema.
+	syncSchemaVersion   = 11
+	searchSchemaVersion = 1
+	// Details are fetched on this many workers, enough to keep the TMDB
+	// limiters busy without queueing every ID of a run behind them.
+	syncDetailWorkers = 40
+	syncIndexWorkers  = 4
+)
+
+var (
+	// Columns, indexes and sequences this sync adds to tables whose schema is
+	// owned elsewhere.
+	addedColumns = []string{
+		`ALTER TABLE "Game" ADD COLUMN IF NOT EXISTS "deletedAt" timestamp(3)`,
+		`ALTER TABLE "Movie" ADD COLUMN IF NOT EXISTS "deletedAt" timestamp(3)`,
+		`ALTER TABLE "TVShow" ADD COLUMN IF NOT EXISTS "deletedAt" timestamp(3)`,
+		`ALTER TABLE "Movie" ADD COLUMN IF NOT EXISTS overview text`,
+		`ALTER TABLE "Movie" ADD COLUMN IF NOT EXISTS tagline text`,
+		`ALTER TABLE "Movie" ADD COLUMN IF NOT EXISTS status text`,
+		`ALTER TABLE "Movie" ADD COLUMN IF NOT EXISTS revenue bigint NOT NULL DEFAULT 0`,
+		`ALTER TABLE "Movie" ADD COLUMN IF NOT EXISTS "imdbId" text`,
+		`ALTER TABLE "Movie" ADD COLUMN IF NOT EXISTS "backdropPath" text`,
+		`ALTER TABLE "Movie" ADD COLUMN IF NOT EXISTS "voteAverage" real NOT NULL DEFAULT 0`,
+		`ALTER TABLE "Movie" ADD COLUMN IF NOT EXISTS "voteCount" integer NOT NULL DEFAULT 0`,
+		`ALTER TABLE "Movie" ADD COLUMN IF NOT EXISTS certification text`,
+		`CREATE INDEX IF NOT EXISTS "Movie_status_idx" ON "Movie" (status)`,
+		widenColumn("Movie", "budget", "bigint"),
+		`ALTER TABLE "Movie" ADD COLUMN IF NOT EXISTS "collectionId" integer`,
+		`CREATE INDEX IF NOT EXISTS "Movie_collectionId_idx" ON "Movie" ("collectionId")`,
+		`ALTER TABLE "CinemaPerson" ADD COLUMN IF NOT EXISTS "profilePath" text`,
+		`ALTER TABLE "CinemaPerson" ADD COLUMN IF NOT EXISTS "knownForDepartment" text`,
+		`ALTER TABLE "CinemaPerson" ADD COLUMN IF NOT EXISTS birthday date`,
+		`ALTER TABLE "CinemaPerson" ADD COLUMN IF NOT EXISTS "placeOfBirth" text`,
+		`ALTER TABLE "CinemaPerson" ADD COLUMN IF NOT EXISTS popularity real NOT NULL DEFAULT 0`,
+		`ALTER TABLE "CinemaPerson" ADD COLUMN IF NOT EXISTS "syncedAt" timestamp(3)`,
+		`CREATE INDEX IF NOT EXISTS "CinemaPerson_unsynced_idx" ON "CinemaPerson" (id) WHERE "syncedAt" IS NULL`,
+		`ALTER TABLE "TVNetwork" ADD COLUMN IF NOT EXISTS "originCountry" text`,
+		`ALTER TABLE "TVNetwork" ADD COLUMN IF NOT EXISTS "syncedAt" timestamp(3)`,
+		// Outbox events are paged on a position assigned in commit order.
+		`ALTER TABLE "SyncOutbox" ADD COLUMN IF NOT EXISTS "txId" xid8 NOT NULL DEFAULT pg_current_xact_id()`,
+		`ALTER TABLE "SyncOutbox" ADD COLUMN IF NOT EXISTS position bigint`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS "SyncOutbox_position_key" ON "SyncOutbox" (position)`,
+		`CREATE INDEX IF NOT EXISTS "SyncOutbox_unpublished_idx" ON "SyncOutbox" ("txId", id) WHERE position IS NULL`,
+		`CREATE SEQUENCE IF NOT EXISTS "SyncOutbox_position_seq"`,
+		`CREATE SEQUENCE IF NOT EXISTS "MReleaseCountry_id_seq"`,
+		`CREATE SEQUENCE IF NOT EXISTS "MLocalRelease_id_seq"`,
+	}
+
+	// One-off data changes, run after the sync schema step. Each runs once, in
+	// order, and is recorded as a version of the sync-data step: append new
+	// ones, never edit or reorder them.
+	syncDataMigrations = []string{
+		// Release countries and dates are keyed on (movieId, iso31661) and
+		// (releaseCountryId, type, releaseDate), duplicates left by the former
+		// positional IDs are dropped first. New rows take IDs from sequences
+		// starting past the positional ones.
+		`DO $$ BEGIN
+			DELETE FROM "MLocalRelease" WHERE "releaseCountryId" IN (SELECT a.id FROM "MReleaseCountry" AS a
+				JOIN "MReleaseCountry" AS b ON b."movieId" = a."movieId" AND b.iso31661 = a.iso31661 AND b.id < a.id);
+			DELETE FROM "MReleaseCountry" AS a USING "MReleaseCountry" AS b
+				WHERE b."movieId" = a."movieId" AND b.iso31661 = a.iso31661 AND b.id < a.id;
+			CREATE UNIQUE INDEX IF NOT EXISTS "MReleaseCountry_movieId_iso31661_key" ON "MReleaseCountry" ("movieId", iso31661);
+			DELETE FROM "MLocalRelease" AS a USING "MLocalRelease" AS b
+				WHERE b."releaseCountryId" = a."releaseCountryId" AND b.type = a.type AND b."releaseDate" = a."releaseDate" AND b.id < a.id;
+			CREATE UNIQUE INDEX IF NOT EXISTS "MLocalRelease_releaseCountryId_type_releaseDate_key" ON "MLocalRelease" ("releaseCountryId", type, "releaseDate");
+			PERFORM setval('"MReleaseCountry_id_seq"', GREATEST((SELECT MAX(id) FROM "MReleaseCountry"), 1));
+			PERFORM setval('"MLocalRelease_id_seq"', GREATEST((SELECT MAX(id) FROM "MLocalRelease"), 1));
+		END $$`,
+		// Seasons were once stored under the show ID, one row per show, and a
+		// show written since has its seasons replaced. Rows left by the old code
+		// are the only season of their show keyed on the show ID. They are
+		// dropped with the show's content hash, so the next run writes every
+		// season under its TMDB season ID.
+		`WITH legacy AS (
+			DELETE FROM "TVSeason" AS s WHERE s.id = s."showId"
+				AND NOT EXISTS (SELECT 1 FROM "TVSeason" AS o WHERE o."showId" = s."showId" AND o.id <> s.id)
+			RETURNING s."showId"
+		)
+		DELETE FROM "MediaContentHash" WHERE "entityType" = 'tv' AND "entityId" IN (SELECT "showId" FROM legacy)`,
+	}
+)
+
+func (e *syncRunningError) Error() string {
+	return fmt.Sprintf("%s sync is already running as run %d", e.Kind, e.RunId)
+}
+
+func SyncRuns(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeSync) {
+		return
+	}
+	db, err := openDB()
+	if err != nil {
+		http.Error(w, "Error connecting to the DB", http.StatusInternalServerError)
+		return
+	}
+
+	var runs []SyncRun
+	query := db.Table("SyncRun").Order(`"startedAt" desc`).Limit(50)
+	if kind := r.URL.Query().Get("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if err := query.Find(&runs).Error; err != nil {
+		http.Error(w, "Error reading sync runs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// SchemaMigration records the version each schema step was last migrated to.
+type SchemaMigration struct {
+	Name      string    `gorm:"primaryKey"`
+	Version   uint16    `gorm:"column:version"`
+	AppliedAt time.Time `gorm:"column:appliedAt"`
+}
+
+// migrateSchema runs the sync and search schema steps that are behind their
+// version, then the sync data migrations not applied yet. They run in separate
+// transactions, so a search index that cannot be built, e.g. without the
+// pg_trgm extension, does not hold back the sync tables, but both errors are
+// returned.
+func migrateSchema(db *gorm.DB) error {
+	if err := createSchemaMigrationTable(db); err != nil {
+		return err
+	}
+	syncErr := migrateSchemaStep(db, "sync", syncSchemaVersion, migrateSyncTables)
+	if syncErr == nil {
+		syncErr = migrateSyncData(db)
+	}
+	return errors.Join(syncErr, migrateSchemaStep(db, "search", searchSchemaVersion, migrateSearchIndexes))
+}
+
+// migrateSearchSchema runs the search schema step alone, for the Search
+// handler on a DB no sync has migrated yet.
+func migrateSearchSchema(db *gorm.DB) error {
+	if err := createSchemaMigrationTable(db); err != nil {
+		return err
+	}
+	return migrateSchemaStep(db, "search", searchSchemaVersion, migrateSearchIndexes)
+}
+
+func createSchemaMigrationTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS "SchemaMigration" (
+		name text PRIMARY KEY,
+		version integer NOT NULL,
+		"appliedAt" timestamp(3) NOT NULL
+	)`).Error
+}
+
+// migrateSyncData runs each of syncDataMigrations once, as version i+1 of the
+// sync-data step, stopping at the first that fails.
+func migrateSyncData(db *gorm.DB) error {
+	for i, statement := range syncDataMigrations {
+		statement := statement
+		err := migrateSchemaStep(db, "sync-data", uint16(i+1), func(tx *gorm.DB) error {
+			return tx.Exec(statement).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateSchemaStep runs migrate when the stored version of name is below
+// version. Runs starting together wait on an advisory lock, so the DDL runs
+// once.
+func migrateSchemaStep(db *gorm.DB, name string, version uint16, migrate func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('SchemaMigration'))`).Error; err != nil {
+			return err
+		}
+		var applied SchemaMigration
+		if err := tx.Table("SchemaMigration").Where("name = ?", name).Limit(1).Find(&applied).Error; err != nil {
+			return err
+		}
+		if applied.Version >= version {
+			return nil
+		}
+		if err := migrate(tx); err != nil {
+			return fmt.Errorf("migrating %s schema to version %d: %w", name, version, err)
+		}
+		fmt.Printf("Migrated %s schema to version %d\n", name, version)
+		migration := SchemaMigration{Name: name, Version: version, AppliedAt: time.Now()}
+		return tx.Table("SchemaMigration").Clauses(clause.OnConflict{UpdateAll: true}).Create(&migration).Error
+	})
+}
+
+// migrateSyncTables creates the tables owned by this sync and adds its columns
+// to the shared ones. Bump syncSchemaVersion when a model or statement changes.
+func migrateSyncTables(db *gorm.DB) error {
+	if err := db.Table("SyncRun").AutoMigrate(&SyncRun{}); err != nil {
+		return err
+	}
+	if err := db.Table("MediaContentHash").AutoMigrate(&MediaContentHash{}); err != nil {
+		return err
+	}
+	if err := db.Table("MediaChangeLog").AutoMigrate(&MediaChangeLog{}); err != nil {
+		return err
+	}
+	if err := db.Table("ReleaseDateHistory").AutoMigrate(&ReleaseDateChange{}); err != nil {
+		return err
+	}
+	if err := db.Table("WebhookOutbox").AutoMigrate(&WebhookDelivery{}); err != nil {
+		return err
+	}
+	if err := db.Table("SyncOutbox").AutoMigrate(&SyncOutboxEvent{}); err != nil {
+		return err
+	}
+	if err := db.Table("SyncOutboxCursor").AutoMigrate(&SyncOutboxCursor{}); err != nil {
+		return err
+	}
+	if err := db.Table("SyncLease").AutoMigrate(&SyncLease{}); err != nil {
+		return err
+	}
+	if err := db.Table("SyncCheckpoint").AutoMigrate(&SyncCheckpoint{}); err != nil {
+		return err
+	}
+	if err := db.Table("ContentFilter").AutoMigrate(&ContentFilter{}); err != nil {
+		return err
+	}
+	if err := db.Table("MovieTranslation").AutoMigrate(&MovieTranslation{}); err != nil {
+		return err
+	}
+	if err := db.Table("TVShowTranslation").AutoMigrate(&TVShowTranslation{}); err != nil {
+		return err
+	}
+	if err := db.Table("MovieCollection").AutoMigrate(&MovieCollection{}); err != nil {
+		return err
+	}
+	if err := db.Table("MovieCollectionPart").AutoMigrate(&MovieCollectionPart{}); err != nil {
+		return err
+	}
+	if err := db.Table("CinemaCompany").AutoMigrate(&CinemaCompany{}); err != nil {
+		return err
+	}
+	if err := db.Table("MovieCompany").AutoMigrate(&MovieCompany{}); err != nil {
+		return err
+	}
+	if err := db.Table("TVShowCompany").AutoMigrate(&TVShowCompany{}); err != nil {
+		return err
+	}
+	if err := db.Table("TVShowActor").AutoMigrate(&TVShowActor{}); err != nil {
+		return err
+	}
+	if err := db.Table("MVideo").AutoMigrate(&MVideo{}); err != nil {
+		return err
+	}
+	if err := db.Table("MImage").AutoMigrate(&MImage{}); err != nil {
+		return err
+	}
+	if err := db.Table("TVVideo").AutoMigrate(&TVVideo{}); err != nil {
+		return err
+	}
+	if err := db.Table("TVImage").AutoMigrate(&TVImage{}); err != nil {
+		return err
+	}
+	if err := db.Table("WatchProvider").AutoMigrate(&WatchProvider{}); err != nil {
+		return err
+	}
+	if err := db.Table("MediaWatchProvider").AutoMigrate(&MediaWatchProvider{}); err != nil {
+		return err
+	}
+	for _, statement := range addedColumns {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startSyncRun records a new run and takes the lease of its kind. It returns a
+// *syncRunningError naming the owning run when another run holds the lease.
+func startSyncRun(db *gorm.DB, kind string) (*SyncRun, error) {
+	if err := migrateSchema(db); err != nil {
+		fmt.Println("Error migrating sync tables:", err)
+		return nil, err
+	}
+
+	run := &SyncRun{
+		Kind:      kind,
+		StartedAt: time.Now(),
+	}
+	run.deadline = run.StartedAt.Add(syncTimeBudget())
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("SyncRun").Create(run).Error; err != nil {
+			return err
+		}
+		return acquireSyncLease(tx, run)
+	})
+	if err != nil {
+		fmt.Println("Error starting sync run:", err)
+		return nil, err
+	}
+
+	run.stopHeartbeat = make(chan struct{})
+	go heartbeatSyncLease(db, run)
+	return run, nil
+}
+
+func acquireSyncLease(tx *gorm.DB, run *SyncRun) error {
+	now := time.Now()
+	result := tx.Exec(`INSERT INTO "SyncLease" (kind, "runId", "heartbeatAt") VALUES (?, ?, ?)
+		ON CONFLICT (kind) DO UPDATE SET "runId" = EXCLUDED."runId", "heartbeatAt" = EXCLUDED."heartbeatAt"
+		WHERE "SyncLease"."heartbeatAt" < ?`, run.Kind, run.ID, now, now.Add(-syncLeaseTTL))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var lease SyncLease
+	if err := tx.Table("SyncLease").Where("kind = ?", run.Kind).Take(&lease).Error; err != nil {
+		return err
+	}
+	return &syncRunningError{Kind: run.Kind, RunId: lease.RunId}
+}
+
+func heartbeatSyncLease(db *gorm.DB, run *SyncRun) {
+	ticker := time.NewTicker(syncLeaseHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-run.stopHeartbeat:
+			return
+		case <-ticker.C:
+			err := db.Table("SyncLease").
+				Where(`kind = ? AND "runId" = ?`, run.Kind, run.ID).
+				Update("heartbeatAt", time.Now()).Error
+			if err != nil {
+				fmt.Println("Error refreshing sync lease:", err)
+			}
+		}
+	}
+}
+
+// widenColumn returns a statement changing the type of a column only when it
+// differs, so repeated migrations do not lock the table.
+func widenColumn(table string, column string, columnType string) string {
+	return fmt.Sprintf(`DO $$ BEGIN
+		IF (SELECT data_type FROM information_schema.columns WHERE table_name = '%[1]s' AND column_name = '%[2]s') <> '%[3]s' THEN
+			ALTER TABLE %[1]q ALTER COLUMN %[2]q TYPE %[3]s;
+		END IF;
+	END $$`, table, column, columnType)
+}
+
+// decodeIngest unmarshals an upstream response after auditing it against the
+// target struct. Out-of-range numbers are logged with their path, and since
+// encoding/json leaves such fields zero and decodes the rest, the record is
+// kept instead of being dropped. A type error on a field the audit did not
+// flag, such as a string where a number belongs, is returned.
+func decodeIngest(body []byte, target interface{}, label string, stats *syncStats) error {
+	issues := auditIngestJSON(body, reflect.TypeOf(target).Elem())
+	for _, issue := range issues {
+		fmt.Printf("Out-of-range value in %s: %s\n", label, issue)
+	}
+	err := json.Unmarshal(body, target)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && slices.ContainsFunc(issues, func(issue ingestIssue) bool { return issue.matches(typeErr.Field) }) {
+		stats.invalid.Add(1)
+		return nil
+	}
+	return err
+}
+
+// ingestIssue is a number that does not fit its field. Path locates the value
+// in the document, Field and Keys name it the two ways json.UnmarshalTypeError
+// does depending on the Go release: Field joins the struct field names,
+// including embedded structs, Keys every object key and array index.
+type ingestIssue struct {
+	Path   string
+	Field  string
+	Keys   string
+	Number json.Number
+	Type   reflect.Type
+}
+
+func (issue ingestIssue) String() string {
+	return fmt.Sprintf("%s = %s does not fit %s", issue.Path, issue.Number, issue.Type)
+}
+
+func (issue ingestIssue) matches(field string) bool {
+	return field == issue.Field || field == issue.Keys
+}
+
+// ingestLocation is where auditIngestValue is in the document.
+type ingestLocation struct {
+	path   string
+	fields []string
+	keys   []string
+}
+
+func (location ingestLocation) child(path string, field string, key string) ingestLocation {
+	child := ingestLocation{path: location.path + path, fields: location.fields, keys: location.keys}
+	if field != "" {
+		child.fields = append(slices.Clip(location.fields), field)
+	}
+	if key != "" {
+		child.keys = append(slices.Clip(location.keys), key)
+	}
+	return child
+}
+
+func (location ingestLocation) issue(number json.Number, targetType reflect.Type) ingestIssue {
+	return ingestIssue{
+		Path:   location.path,
+		Field:  strings.Join(location.fields, "."),
+		Keys:   strings.Join(location.keys, "."),
+		Number: number,
+		Type:   targetType,
+	}
+}
+
+func auditIngestJSON(body []byte, targetType reflect.Type) []ingestIssue {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+	var issues []ingestIssue
+	auditIngestValue(value, targetType, ingestLocation{path: "$"}, &issues)
+	return issues
+}
+
+func auditIngestValue(value interface{}, targetType reflect.Type, location ingestLocation, issues *[]ingestIssue) {
+	for targetType.Kind() == reflect.Pointer {
+		targetType = targetType.Elem()
+	}
+	switch targetType.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok || targetType == reflect.TypeOf(time.Time{}) {
+			return
+		}
+		for i := 0; i < targetType.NumField(); i++ {
+			field := targetType.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			// Untagged embedded structs are decoded inline, and their name is
+			// part of the error field path.
+			if field.Anonymous && name == "" && indirectType(field.Type).Kind() == reflect.Struct {
+				auditIngestValue(value, field.Type, location.child("", field.Name, ""), issues)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			for key, child := range object {
+				if strings.EqualFold(key, name) {
+					auditIngestValue(child, field.Type, location.child("."+key, name, key), issues)
+					break
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			auditIngestValue(item, targetType.Elem(), location.child(fmt.Sprintf("[%d]", i), "", strconv.Itoa(i)), issues)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, child := range object {
+			auditIngestValue(child, targetType.Elem(), location.child("."+key, "", key), issues)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if number, ok := value.(json.Number); ok {
+			if _, err := strconv.ParseInt(number.String(), 10, targetType.Bits()); err != nil {
+				*issues = append(*issues, location.issue(number, targetType))
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if number, ok := value.(json.Number); ok {
+			if _, err := strconv.ParseUint(number.String(), 10, targetType.Bits()); err != nil {
+				*issues = append(*issues, location.issue(number, targetType))
+			}
+		}
+	case reflect.Float32:
+		if number, ok := value.(json.Number); ok {
+			if _, err := strconv.ParseFloat(number.String(), 32); err != nil {
+				*issues = append(*issues, location.issue(number, targetType))
+			}
+		}
+	}
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func (stats *syncStats) markDeleted(id uint32) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.deletedIds = append(stats.deletedIds, id)
+}
+
+// tombstoneEntities sets deletedAt on entities removed upstream and records
+// a "deleted" outbox event for each. The next upsert of a restored entity
+// clears deletedAt again, so its content hash is dropped to force that write.
+func tombstoneEntities(db *gorm.DB, table string, entityType string, ids []uint32) uint32 {
+	if len(ids) == 0 {
+		return 0
+	}
+	var deleted []uint32
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(fmt.Sprintf(`UPDATE %q SET "deletedAt" = ? WHERE id IN ? AND "deletedAt" IS NULL RETURNING id`, table),
+			time.Now(), ids).Scan(&deleted).Error
+		if err != nil || len(deleted) == 0 {
+			return err
+		}
+
+		if err := tx.Table("MediaContentHash").Where(`"entityType" = ? AND "entityId" IN ?`, entityType, deleted).Delete(&MediaContentHash{}).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		events := make([]SyncOutboxEvent, 0, len(deleted))
+		for _, id := range deleted {
+			events = append(events, SyncOutboxEvent{
+				EntityType: entityType,
+				EntityId:   id,
+				Action:     "deleted",
+				Fields:     []string{"deletedAt"},
+				CreatedAt:  now,
+			})
+		}
+		return tx.Table("SyncOutbox").Create(&events).Error
+	})
+	if err != nil {
+		fmt.Printf("Error marking deleted %s entities: %v\n", entityType, err)
+		return 0
+	}
+	if len(deleted) > 0 {
+		fmt.Printf("Marked %d %s entities as deleted: %v\n", len(deleted), entityType, deleted)
+	}
+	return uint32(len(deleted))
+}
+
+func syncTimeBudget() time.Duration {
+	if budget, err := time.ParseDuration(os.Getenv("SYNC_TIME_BUDGET")); err == nil && budget > 0 {
+		return budget
+	}
+	return defaultSyncTimeBudget
+}
+
+// pastDeadline reports whether the run has used up its time budget and should
+// stop pulling new pages or IDs.
+func (run *SyncRun) pastDeadline() bool {
+	return time.Now().After(run.deadline)
+}
+
+// fetchSyncIds calls fetch for every distinct ID read from idsCh on
+// syncDetailWorkers workers. A worker checks the deadline once limiter has
+// handed it a token, so IDs still queued in the limiter when the budget runs
+// out are returned, sorted, for the checkpoint instead of being fetched late.
+func fetchSyncIds(run *SyncRun, limiter *rate.Limiter, idsCh <-chan uint32, fetch func(id uint32)) pq.Int64Array {
+	queue := make(chan uint32)
+	var mu sync.Mutex
+	var pendingIds pq.Int64Array
+	var wg sync.WaitGroup
+	for i := 0; i < syncDetailWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range queue {
+				if !run.pastDeadline() {
+					if err := limiter.Wait(context.Background()); err != nil {
+						fmt.Printf("Rate limit exceeded for ID %d: %v\n", id, err)
+					}
+				}
+				if run.pastDeadline() {
+					mu.Lock()
+					pendingIds = append(pendingIds, int64(id))
+					mu.Unlock()
+					continue
+				}
+				fetch(id)
+			}
+		}()
+	}
+
+	seen := map[uint32]bool{}
+	for id := range idsCh {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		queue <- id
+	}
+	close(queue)
+	wg.Wait()
+	sort.Slice(pendingIds, func(i, j int) bool { return pendingIds[i] < pendingIds[j] })
+	return pendingIds
+}
+
+// fetchIndexPages calls fetch for the index pages from..to on
+// syncIndexWorkers workers and stops taking pages once the run is past its
+// deadline. It returns the first page left out, 0 when all were fetched.
+func fetchIndexPages(run *SyncRun, from uint32, to uint32, fetch func(pageNum uint32)) uint32 {
+	pages := make(chan uint32)
+	var mu sync.Mutex
+	var skipped uint32
+	var wg sync.WaitGroup
+	for i := 0; i < syncIndexWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pageNum := range pages {
+				if run.pastDeadline() {
+					mu.Lock()
+					if skipped == 0 || pageNum < skipped {
+						skipped = pageNum
+					}
+					mu.Unlock()
+					continue
+				}
+				fetch(pageNum)
+			}
+		}()
+	}
+	for pageNum := from; pageNum >= from && pageNum <= to; pageNum++ {
+		pages <- pageNum
+	}
+	close(pages)
+	wg.Wait()
+	return skipped
+}
+
+// markIdsPartial marks a run that fetches TMDB IDs as partial when IDs were
+// left for the next run or index pages were skipped. IDs listed on skipped
+// pages are not known, they are picked up again while the change feed still
+// lists them.
+func markIdsPartial(run *SyncRun, pendingIds pq.Int64Array, skippedPage uint32) {
+	switch {
+	case len(pendingIds) > 0 && skippedPage > 0:
+		run.markPartial(&SyncCheckpoint{PendingIds: pendingIds}, fmt.Sprintf("ID %d (%d IDs pending, index pages from %d skipped)", pendingIds[0], len(pendingIds), skippedPage))
+	case len(pendingIds) > 0:
+		run.markPartial(&SyncCheckpoint{PendingIds: pendingIds}, fmt.Sprintf("ID %d (%d IDs pending)", pendingIds[0], len(pendingIds)))
+	case skippedPage > 0:
+		run.markPartial(&SyncCheckpoint{}, fmt.Sprintf("index page %d", skippedPage))
+	}
+}
+
+// markPartial records where the next run should resume. It is saved by
+// finishSyncRun once the fetched batches are flushed.
+func (run *SyncRun) markPartial(checkpoint *SyncCheckpoint, resumeFrom string) {
+	checkpoint.Kind = run.Kind
+	run.checkpoint = checkpoint
+	run.Partial = true
+	run.ResumeFrom = &resumeFrom
+}
+
+func loadSyncCheckpoint(db *gorm.DB, kind string) SyncCheckpoint {
+	var checkpoint SyncCheckpoint
+	if err := db.Table("SyncCheckpoint").Where("kind = ?", kind).Limit(1).Find(&checkpoint).Error; err != nil {
+		fmt.Println("Error reading sync checkpoint:", err)
+	}
+	return checkpoint
+}
+
+func saveSyncCheckpoint(db *gorm.DB, run *SyncRun) {
+	var err error
+	if run.checkpoint != nil {
+		run.checkpoint.UpdatedAt = time.Now()
+		err = db.Table("SyncCheckpoint").Clauses(clause.OnConflict{UpdateAll: true}).Create(run.checkpoint).Error
+	} else {
+		err = db.Table("SyncCheckpoint").Where("kind = ?", run.Kind).Delete(&SyncCheckpoint{}).Error
+	}
+	if err != nil {
+		fmt.Println("Error saving sync checkpoint:", err)
+	}
+}
+
+func releaseSyncLease(db *gorm.DB, run *SyncRun) {
+	close(run.stopHeartbeat)
+	err := db.Table("SyncLease").
+		Where(`kind = ? AND "runId" = ?`, run.Kind, run.ID).
+		Delete(&SyncLease{}).Error
+	if err != nil {
+		fmt.Println("Error releasing sync lease:", err)
+	}
+}
+
+func finishSyncRun(db *gorm.DB, run *SyncRun, stats *syncStats) {
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Skipped = stats.skipped.Load()
+	run.Filtered = stats.filteredCount()
+	run.Failed = stats.failedCount()
+
+	saveSyncCheckpoint(db, run)
+	if err := db.Table("SyncRun").Save(run).Error; err != nil {
+		fmt.Println("Error recording sync run:", err)
+	}
+	// Webhooks are delivered while the lease is held, within what is left of
+	// the function limit. Deliveries still due are sent by the next run or the
+	// Webhooks handler.
+	delivered, failed := deliverWebhooks(db, run.StartedAt.Add(webhookDeliveryBudget))
+	if delivered > 0 || failed > 0 {
+		fmt.Printf("Delivered %d webhooks, %d failed\n", delivered, failed)
+	}
+	releaseSyncLease(db, run)
+
+	fmt.Printf("Finished %s sync run %d: %d unchanged entities skipped, %d filtered out, %d deleted upstream, %d failed to write\n", run.Kind, run.ID, run.Skipped, run.Filtered, run.Deleted, run.Failed)
+	fmt.Print(stats.filteredSummary())
+	if invalid := stats.invalid.Load(); invalid > 0 {
+		fmt.Printf("Kept %d upstream records with out-of-range values zeroed\n", invalid)
+	}
+	if run.Partial {
+		fmt.Printf("Sync run %d is partial, resume from %s\n", run.ID, *run.ResumeFrom)
+	}
+}
+
+// markFailed records the entities with rows in a batch that failed to write,
+// so their content hash is not stored and the next run writes them again.
+func (stats *syncStats) markFailed(ids ...uint32) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	if stats.failedIds == nil {
+		stats.failedIds = map[uint32]bool{}
+	}
+	for _, id := range ids {
+		stats.failedIds[id] = true
+	}
+}
+
+// markRefsFailed records a failed batch of people, companies or networks.
+// Those rows are shared between entities, so no content hash of the run is
+// stored.
+func (stats *syncStats) markRefsFailed() {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.refsFailed = true
+}
+
+func (stats *syncStats) failedCount() uint32 {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	return uint32(len(stats.failedIds))
+}
+
+func (stats *syncStats) writeFailed(id uint32) bool {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	return stats.refsFailed || stats.failedIds[id]
+}
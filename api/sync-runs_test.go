@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestFetchSyncIds(t *testing.T) {
+	ids := func(values ...uint32) chan uint32 {
+		idsCh := make(chan uint32, len(values))
+		for _, id := range values {
+			idsCh <- id
+		}
+		close(idsCh)
+		return idsCh
+	}
+
+	t.Run("fetches each ID once", func(t *testing.T) {
+		run := &SyncRun{deadline: time.Now().Add(time.Minute)}
+		var mu sync.Mutex
+		fetched := map[uint32]int{}
+		pending := fetchSyncIds(run, rate.NewLimiter(rate.Inf, 1), ids(3, 1, 2, 3, 1), func(id uint32) {
+			mu.Lock()
+			fetched[id]++
+			mu.Unlock()
+		})
+		if len(pending) != 0 {
+			t.Errorf("pending = %v, want none", pending)
+		}
+		for _, id := range []uint32{1, 2, 3} {
+			if fetched[id] != 1 {
+				t.Errorf("ID %d fetched %d times, want 1", id, fetched[id])
+			}
+		}
+	})
+
+	t.Run("checkpoints IDs past the deadline", func(t *testing.T) {
+		run := &SyncRun{deadline: time.Now().Add(-time.Second)}
+		pending := fetchSyncIds(run, rate.NewLimiter(rate.Inf, 1), ids(9, 4, 7, 4), func(id uint32) {
+			t.Errorf("ID %d fetched past the deadline", id)
+		})
+		want := []int64{4, 7, 9}
+		if len(pending) != len(want) {
+			t.Fatalf("pending = %v, want %v", pending, want)
+		}
+		for i := range want {
+			if pending[i] != want[i] {
+				t.Errorf("pending = %v, want %v", pending, want)
+			}
+		}
+	})
+
+	t.Run("checks the deadline after the limiter wait", func(t *testing.T) {
+		run := &SyncRun{deadline: time.Now().Add(50 * time.Millisecond)}
+		// One token up front, the next one only after the deadline.
+		limiter := rate.NewLimiter(rate.Every(200*time.Millisecond), 1)
+		var mu sync.Mutex
+		var fetched []uint32
+		pending := fetchSyncIds(run, limiter, ids(1, 2), func(id uint32) {
+			mu.Lock()
+			fetched = append(fetched, id)
+			mu.Unlock()
+		})
+		if len(fetched) != 1 || len(pending) != 1 {
+			t.Errorf("fetched %v, pending %v, want one of each", fetched, pending)
+		}
+	})
+}
+
+func TestFetchIndexPages(t *testing.T) {
+	run := &SyncRun{deadline: time.Now().Add(time.Minute)}
+	var mu sync.Mutex
+	fetched := map[uint32]bool{}
+	if skipped := fetchIndexPages(run, 2, 9, func(pageNum uint32) {
+		mu.Lock()
+		fetched[pageNum] = true
+		mu.Unlock()
+	}); skipped != 0 {
+		t.Errorf("skipped = %d, want 0", skipped)
+	}
+	if len(fetched) != 8 {
+		t.Errorf("fetched %d pages, want 8", len(fetched))
+	}
+
+	run.deadline = time.Now().Add(-time.Second)
+	if skipped := fetchIndexPages(run, 2, 9, func(pageNum uint32) {
+		t.Errorf("page %d fetched past the deadline", pageNum)
+	}); skipped != 2 {
+		t.Errorf("skipped = %d, want 2", skipped)
+	}
+}
+
+func TestDecodeIngest(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		target      func() interface{}
+		wantErr     bool
+		wantFields  []string
+		wantInvalid uint32
+		check       func(t *testing.T, target interface{})
+	}{
+		{
+			fixture: "movie-large-budget.json",
+			target:  func() interface{} { return &Movie{} },
+			check: func(t *testing.T, target interface{}) {
+				movie := target.(*Movie)
+				if movie.Budget != 5000000000 || movie.Revenue != 2923706026 {
+					t.Errorf("budget %d, revenue %d, want 5000000000 and 2923706026", movie.Budget, movie.Revenue)
+				}
+			},
+		},
+		{
+			fixture: "movie-changes-many-pages.json",
+			target:  func() interface{} { return &Response{} },
+			check: func(t *testing.T, target interface{}) {
+				page := target.(*Response)
+				if page.Page != 300 || page.TotalPages != 1200 || page.TotalResults != 119842 {
+					t.Errorf("page %d of %d, %d results, want page 300 of 1200, 119842 results", page.Page, page.TotalPages, page.TotalResults)
+				}
+			},
+		},
+		{
+			fixture: "movie-changes-past-uint16-pages.json",
+			target:  func() interface{} { return &TVResponse{} },
+			check: func(t *testing.T, target interface{}) {
+				page := target.(*TVResponse)
+				if page.TotalPages != 70000 || page.TotalResults != 7000000 || len(page.Results) != 1 {
+					t.Errorf("got %+v, want 70000 pages of 7000000 results", page)
+				}
+			},
+		},
+		{
+			fixture:     "movie-negative-numbers.json",
+			target:      func() interface{} { return &Movie{} },
+			wantFields:  []string{"runtime", "vote_count"},
+			wantInvalid: 1,
+			check: func(t *testing.T, target interface{}) {
+				movie := target.(*Movie)
+				if movie.Runtime != 0 || movie.VoteCount != 0 || movie.Title != "Fight Club" || movie.Budget != 63000000 {
+					t.Errorf("got %+v, want runtime and vote_count zeroed and the rest decoded", movie)
+				}
+			},
+		},
+		{
+			fixture:     "movie-release-type-overflow.json",
+			target:      func() interface{} { return &Movie{} },
+			wantFields:  []string{"release_dates.results.release_dates.type"},
+			wantInvalid: 1,
+			check: func(t *testing.T, target interface{}) {
+				releases := target.(*Movie).ReleaseDates.Results[0].LocalReleaseDates
+				if len(releases) != 2 || releases[0].Type != 0 || releases[1].Type != 1 {
+					t.Errorf("got %+v, want the first type zeroed", releases)
+				}
+			},
+		},
+		{
+			fixture:    "movie-wrong-type.json",
+			target:     func() interface{} { return &Movie{} },
+			wantErr:    true,
+			wantFields: []string{"runtime"},
+		},
+		{
+			fixture:     "games-negative-id.json",
+			target:      func() interface{} { return &[]Game{} },
+			wantFields:  []string{"id"},
+			wantInvalid: 1,
+			check: func(t *testing.T, target interface{}) {
+				games := *target.(*[]Game)
+				if len(games) != 2 || games[0].ID != 1942 || games[1].ID != 0 || games[1].Name != "Broken" {
+					t.Errorf("got %+v, want the second ID zeroed", games)
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", test.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			target := test.target()
+
+			var fields []string
+			for _, issue := range auditIngestJSON(body, reflect.TypeOf(target).Elem()) {
+				fields = append(fields, issue.Field)
+			}
+			if !sameStrings(fields, test.wantFields) {
+				t.Errorf("audited fields %v, want %v", fields, test.wantFields)
+			}
+
+			var stats syncStats
+			err = decodeIngest(body, target, test.fixture, &stats)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("decodeIngest error = %v, want error %v", err, test.wantErr)
+			}
+			if invalid := stats.invalid.Load(); invalid != test.wantInvalid {
+				t.Errorf("invalid = %d, want %d", invalid, test.wantInvalid)
+			}
+			if test.check != nil {
+				test.check(t, target)
+			}
+		})
+	}
+}
+
+// sameStrings compares two lists ignoring order, the audit walks objects in
+// map order.
+func sameStrings(got []string, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	counts := map[string]int{}
+	for _, value := range got {
+		counts[value]++
+	}
+	for _, value := range want {
+		counts[value]--
+		if counts[value] < 0 {
+			return false
+		}
+	}
+	return true
+}
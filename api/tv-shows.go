@@ -2,7 +2,7 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,44 +13,65 @@ import (
 	"github.com/lib/pq"
 	"golang.org/x/time/rate"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 // JSON structs
 type TVShow struct {
-	ID                  uint32              `json:"id"`
-	Name                string              `json:"name"`
-	CreatedBy           []Creator           `json:"created_by"`
-	EpisodeRunTimes     []int32             `json:"episode_run_time"`
-	FirstAirDate        string              `json:"first_air_date"`
-	LastAirDate         string              `json:"last_air_date"`
-	Genres              []Genre             `json:"genres"`
-	InProduction        bool                `json:"in_production"`
-	Languages           []string            `json:"languages"`
-	Networks            []Network           `json:"Networks"`
-	OriginCountries     []string            `json:"origin_country"`
-	OriginalLanguage    string              `json:"original_language"`
-	OriginalName        string              `json:"original_name"`
-	Popularity          float32             `json:"popularity"`
-	PosterPath          *string             `json:"poster_path"`
-	ProductionCountries []ProductionCountry `json:"production_countries"`
-	Seasons             []TVSeason          `json:"seasons"`
-	Status              string              `json:"status"`
-	Type                string              `json:"type"`
-	VoteAverage         float32             `json:"vote_average"`
-}
-
-type Creator struct {
-	ID   uint32 `json:"id"`
-	Name string `json:"name"`
+	ID                  uint32               `json:"id"`
+	Name                string               `json:"name"`
+	CreatedBy           []Person             `json:"created_by"`
+	EpisodeRunTimes     []int32              `json:"episode_run_time"`
+	FirstAirDate        string               `json:"first_air_date"`
+	LastAirDate         string               `json:"last_air_date"`
+	Genres              []Genre              `json:"genres"`
+	InProduction        bool                 `json:"in_production"`
+	Languages           []string             `json:"languages"`
+	Networks            []Company            `json:"networks"`
+	OriginCountries     []string             `json:"origin_country"`
+	OriginalLanguage    string               `json:"original_language"`
+	OriginalName        string               `json:"original_name"`
+	Popularity          float32              `json:"popularity"`
+	PosterPath          *string              `json:"poster_path"`
+	ProductionCountries []ProductionCountry  `json:"production_countries"`
+	Seasons             []TVSeason           `json:"seasons"`
+	Status              string               `json:"status"`
+	Type                string               `json:"type"`
+	VoteAverage         float32              `json:"vote_average"`
+	Translations        MediaTranslations    `json:"translations"`
+	WatchProviders      WatchProviderResults `json:"watch/providers"`
+	Videos              MediaVideos          `json:"videos"`
+	Images              MediaImages          `json:"images"`
+	ProductionCompanies []Company            `json:"production_companies"`
+	AggregateCredits    TVAggregateCredits   `json:"aggregate_credits"`
+}
+
+type TVAggregateCredits struct {
+	Cast []TVAggregateCast `json:"cast"`
+}
+
+// TVAggregateCast is one person across the seasons of a show, with a role per
+// character played. TMDB does not tell series regulars from guest stars here,
+// only the episode count does.
+type TVAggregateCast struct {
+	ID                uint32       `json:"id"`
+	Name              string       `json:"name"`
+	Roles             []TVCastRole `json:"roles"`
+	TotalEpisodeCount uint16       `json:"total_episode_count"`
+	Order             uint16       `json:"order"`
+}
+
+type TVCastRole struct {
+	Character    string `json:"character"`
+	EpisodeCount uint16 `json:"episode_count"`
 }
 
 type Network struct {
-	ID       uint32  `json:"id"`
-	Name     string  `json:"name"`
-	LogoPath *string `json:"logo_path" gorm:"column:logoPath"`
+	ID            uint32  `json:"id"`
+	Name          string  `json:"name"`
+	LogoPath      *string `json:"logo_path" gorm:"column:logoPath"`
+	OriginCountry *string `json:"origin_country" gorm:"column:originCountry"`
 }
 
 // DB structs
@@ -69,7 +90,8 @@ type TVShowBase struct {
 	PosterPath       *string `gorm:"column:posterPath"`
 	Status           string
 	Type             string
-	VoteAverage      float32 `gorm:"column:voteAverage"`
+	VoteAverage      float32    `gorm:"column:voteAverage"`
+	DeletedAt        *time.Time `json:"-" gorm:"column:deletedAt"`
 }
 
 type TVSeason struct {
@@ -93,6 +115,32 @@ type TVSeasonDB struct {
 	VoteAverage  float32 `json:"vote_average" gorm:"column:voteAverage"`
 }
 
+// tvShowSeasons carries every season of one TV show, including specials as
+// season 0, so the writer can drop the seasons TMDB no longer lists.
+type tvShowSeasons struct {
+	ShowId  uint32
+	Seasons []TVSeasonDB
+}
+
+// tvSeasonRows maps every season TMDB lists for the show, specials included,
+// to a row keyed by its TMDB season ID.
+func tvSeasonRows(show TVShow) []TVSeasonDB {
+	seasons := make([]TVSeasonDB, 0, len(show.Seasons))
+	for _, season := range show.Seasons {
+		seasons = append(seasons, TVSeasonDB{
+			ShowID:       show.ID,
+			ID:           season.ID,
+			Name:         season.Name,
+			SeasonNumber: season.SeasonNumber,
+			PosterPath:   season.PosterPath,
+			AirDate:      filterEmptyDates(season.AirDate),
+			EpisodeCount: season.EpisodeCount,
+			VoteAverage:  season.VoteAverage,
+		})
+	}
+	return seasons
+}
+
 type TVShowGenre struct {
 	ShowId  uint32 `gorm:"column:showId"`
 	GenreId uint32 `gorm:"column:genreId"`
@@ -103,6 +151,21 @@ type TVShowCreator struct {
 	CreatorId uint32 `gorm:"column:creatorId"`
 }
 
+type TVShowActor struct {
+	ShowId       uint32         `gorm:"primaryKey;autoIncrement:false;column:showId"`
+	ActorId      uint32         `gorm:"primaryKey;autoIncrement:false;column:actorId;index"`
+	Characters   pq.StringArray `gorm:"type:text[]"`
+	EpisodeCount uint16         `gorm:"column:episodeCount"`
+	Position     uint16
+}
+
+// tvShowActors carries the whole cast of one TV show, so the writer can
+// replace it and drop the actors TMDB no longer lists.
+type tvShowActors struct {
+	ShowId uint32
+	Actors []TVShowActor
+}
+
 type TVShowNetwork struct {
 	ShowId    uint32 `gorm:"column:showId"`
 	NetworkId uint32 `gorm:"column:networkId"`
@@ -118,11 +181,37 @@ type TVShowProdCountry struct {
 	CountryIso string `gorm:"column:countryIso"`
 }
 
+type TVShowTranslation struct {
+	ShowId   uint32 `gorm:"primaryKey;autoIncrement:false;column:showId"`
+	Language string `gorm:"primaryKey"`
+	Name     string
+	Overview *string
+	Tagline  *string
+}
+
+type TVVideo struct {
+	MediaVideo
+	ShowId uint32 `json:"-" gorm:"column:showId;index"`
+}
+
+type TVImage struct {
+	ShowId uint32 `json:"-" gorm:"primaryKey;autoIncrement:false;column:showId"`
+	MediaImage
+}
+
+// tvShowMedia carries every video and image of one TV show, so the writer can
+// replace them as a whole.
+type tvShowMedia struct {
+	ShowId uint32
+	Videos []TVVideo
+	Images []TVImage
+}
+
 type TVResponse struct {
 	Results      []TVShowIndex `json:"results"`
-	Page         uint16        `json:"page"`
-	TotalPages   uint16        `json:"total_pages"`
-	TotalResults uint16        `json:"total_results"`
+	Page         uint32        `json:"page"`
+	TotalPages   uint32        `json:"total_pages"`
+	TotalResults uint32        `json:"total_results"`
 }
 
 type TVShowIndex struct {
@@ -135,11 +224,27 @@ var (
 )
 
 func TVShows(w http.ResponseWriter, r *http.Request) {
-	updateTVShows()
+	if !authorize(w, r, scopeSync) {
+		return
+	}
+	run, err := updateTVShows()
+	if err != nil {
+		var running *syncRunningError
+		if errors.As(err, &running) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error updating TV shows DB", http.StatusInternalServerError)
+		return
+	}
+	if run.Partial {
+		fmt.Fprintf(w, "Partially updated TV shows DB, resume from %s", *run.ResumeFrom)
+		return
+	}
 	fmt.Fprintf(w, "Finished updating TV shows DB")
 }
 
-func fetchTVIndexData(PageNum uint16) ([]byte, error) {
+func fetchTVIndexData(PageNum uint32) ([]byte, error) {
 	if err := televisionLimiter.Wait(context.Background()); err != nil {
 		fmt.Printf("Rate limit exceeded for Page %d: %v\n", PageNum, err)
 	}
@@ -166,14 +271,14 @@ func fetchTVIndexData(PageNum uint16) ([]byte, error) {
 	return body, nil
 }
 
-func fetchAndProcessTVIndexData(pageNum uint16, idsCh chan uint32) {
+func fetchAndProcessTVIndexData(pageNum uint32, filter ContentFilter, stats *syncStats, idsCh chan uint32) {
 	body, err := fetchTVIndexData(pageNum)
 	if err != nil {
 		fmt.Printf("Error fetching the first index page: %v\n", err)
 		return
 	}
 	var rawInitData TVResponse
-	err = json.Unmarshal(body, &rawInitData)
+	err = decodeIngest(body, &rawInitData, fmt.Sprintf("TV changes page %d", pageNum), stats)
 	if err != nil {
 		fmt.Printf("Error unmarshalling the first index page: %v\n", err)
 		return
@@ -182,18 +287,19 @@ func fetchAndProcessTVIndexData(pageNum uint16, idsCh chan uint32) {
 		totalPages = rawInitData.TotalPages
 	}
 	for _, entry := range rawInitData.Results {
-		if !entry.Adult {
-			idsCh <- entry.ID
+		if filter.ExcludeAdult && entry.Adult {
+			stats.markFiltered(entry.ID, "adult")
+			stats.markDeleted(entry.ID)
+			continue
 		}
+		idsCh <- entry.ID
 	}
 }
 
+// fetchTVDetailsData fetches the details of a show. The caller waits on
+// televisionLimiter, see fetchSyncIds.
 func fetchTVDetailsData(id uint32) ([]byte, error) {
-	if err := televisionLimiter.Wait(context.Background()); err != nil {
-		fmt.Printf("Rate limit exceeded for Page %d: %v\n", id, err)
-	}
-
-	url := fmt.Sprintf("https://api.themoviedb.org/3/tv/%d?language=en-US", id)
+	url := fmt.Sprintf("https://api.themoviedb.org/3/tv/%d?append_to_response=translations%%2Cwatch%%2Fproviders%%2Cvideos%%2Cimages%%2Caggregate_credits&language=en-US&%s", id, mediaLanguageQuery())
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -205,6 +311,9 @@ func fetchTVDetailsData(id uint32) ([]byte, error) {
 		return nil, err
 	}
 	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
 	if res.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("unexpected HTTP status code: %d", res.StatusCode)
 	}
@@ -215,49 +324,68 @@ func fetchTVDetailsData(id uint32) ([]byte, error) {
 	return body, nil
 }
 
-func fetchAndProcessTVDetailsData(id uint32, showBaseCh chan TVShowBase, seasonCh chan TVSeasonDB, genreCh chan TVShowGenre, creatorRefCh chan Creator, creatorCh chan TVShowCreator, networkRefCh chan Network, networkCh chan TVShowNetwork, origCountryCh chan TVShowOrigCountry, prodCountryCh chan TVShowProdCountry) {
+type tvShowRows struct {
+	Base           TVShowBase
+	Seasons        []TVSeasonDB
+	Genres         []TVShowGenre
+	People         []Person
+	Creators       []TVShowCreator
+	Actors         []TVShowActor
+	NetworkRefs    []Network
+	Networks       []TVShowNetwork
+	CompanyRefs    []CinemaCompany
+	Companies      []TVShowCompany
+	OrigCountries  []TVShowOrigCountry
+	ProdCountries  []TVShowProdCountry
+	Translations   []TVShowTranslation
+	WatchProviders []MediaWatchProvider
+	Videos         []TVVideo
+	Images         []TVImage
+}
+
+func fetchAndProcessTVDetailsData(id uint32, db *gorm.DB, filter ContentFilter, stats *syncStats, hashCh chan MediaContentHash, changeLogCh chan MediaChangeLog, showBaseCh chan TVShowBase, seasonCh chan tvShowSeasons, genreCh chan TVShowGenre, peopleRefCh chan Person, creatorCh chan TVShowCreator, actorCh chan tvShowActors, networkRefCh chan Network, networkCh chan TVShowNetwork, companyRefCh chan CinemaCompany, companyCh chan TVShowCompany, origCountryCh chan TVShowOrigCountry, prodCountryCh chan TVShowProdCountry, translationCh chan TVShowTranslation, watchProviderCh chan mediaWatchProviders, mediaCh chan tvShowMedia) {
 	body, err := fetchTVDetailsData(id)
+	if errors.Is(err, errNotFound) {
+		stats.markDeleted(id)
+		return
+	}
 	if err != nil {
 		fmt.Printf("Error fetching details for ID %d: %v\n", id, err)
 		return
 	}
 	var show TVShow
-	err = json.Unmarshal(body, &show)
+	err = decodeIngest(body, &show, fmt.Sprintf("TV show %d", id), stats)
 	if err != nil {
 		fmt.Println("Error parsing JSON data for Movie ID:", id, err)
 		return
 	}
 
-	showBaseCh <- TVShowBase{
-		ID:               show.ID,
-		Name:             show.Name,
-		EpisodeRunTimes:  show.EpisodeRunTimes,
-		FirstAirDate:     filterEmptyDates(show.FirstAirDate),
-		LastAirDate:      filterEmptyDates(show.LastAirDate),
-		InProduction:     show.InProduction,
-		Languages:        show.Languages,
-		OriginalLanguage: show.OriginalLanguage,
-		OriginalName:     show.OriginalName,
-		Popularity:       show.Popularity,
-		PosterPath:       show.PosterPath,
-		Status:           show.Status,
-		Type:             show.Type,
-		VoteAverage:      show.VoteAverage,
+	if reason := filter.mediaReason(&show.OriginalLanguage, show.Popularity); reason != "" {
+		stats.markFiltered(id, reason)
+		return
 	}
 
-	for _, season := range show.Seasons {
-		seasonCh <- TVSeasonDB{
-			ShowID:       show.ID,
-			ID:           id,
-			Name:         season.Name,
-			SeasonNumber: season.SeasonNumber,
-			PosterPath:   season.PosterPath,
-			AirDate:      filterEmptyDates(season.AirDate),
-			EpisodeCount: season.EpisodeCount,
-			VoteAverage:  season.VoteAverage,
-		}
+	rows := tvShowRows{
+		Base: TVShowBase{
+			ID:               show.ID,
+			Name:             show.Name,
+			EpisodeRunTimes:  show.EpisodeRunTimes,
+			FirstAirDate:     filterEmptyDates(show.FirstAirDate),
+			LastAirDate:      filterEmptyDates(show.LastAirDate),
+			InProduction:     show.InProduction,
+			Languages:        show.Languages,
+			OriginalLanguage: show.OriginalLanguage,
+			OriginalName:     show.OriginalName,
+			Popularity:       show.Popularity,
+			PosterPath:       show.PosterPath,
+			Status:           show.Status,
+			Type:             show.Type,
+			VoteAverage:      show.VoteAverage,
+		},
 	}
 
+	rows.Seasons = tvSeasonRows(show)
+
 	for _, genre := range show.Genres {
 		legalGenres := [...]uint32{16, 18, 35, 37, 80, 99, 9648, 10751, 10759, 10762, 10763, 10764, 10765, 10766, 10767, 10768}
 
@@ -267,126 +395,272 @@ func fetchAndProcessTVDetailsData(id uint32, showBaseCh chan TVShowBase, seasonC
 		}
 
 		if genresSet[uint32(genre.ID)] {
-			genreCh <- TVShowGenre{
+			rows.Genres = append(rows.Genres, TVShowGenre{
 				ShowId:  show.ID,
 				GenreId: uint32(genre.ID),
-			}
+			})
 		}
 	}
 
 	for _, creator := range show.CreatedBy {
-		creatorRefCh <- creator
-
-		creatorCh <- TVShowCreator{
+		rows.People = append(rows.People, creator)
+		rows.Creators = append(rows.Creators, TVShowCreator{
 			ShowId:    show.ID,
 			CreatorId: creator.ID,
+		})
+	}
+
+	for _, actor := range show.AggregateCredits.Cast {
+		characters := pq.StringArray{}
+		for _, role := range actor.Roles {
+			if role.Character != "" {
+				characters = append(characters, role.Character)
+			}
 		}
+		rows.People = append(rows.People, Person{ID: actor.ID, Name: actor.Name})
+		rows.Actors = append(rows.Actors, TVShowActor{
+			ShowId:       show.ID,
+			ActorId:      actor.ID,
+			Characters:   characters,
+			EpisodeCount: actor.TotalEpisodeCount,
+			Position:     actor.Order,
+		})
 	}
 
 	for _, network := range show.Networks {
-		networkRefCh <- network
-
-		networkCh <- TVShowNetwork{
+		rows.NetworkRefs = append(rows.NetworkRefs, networkRef(network))
+		rows.Networks = append(rows.Networks, TVShowNetwork{
 			ShowId:    show.ID,
 			NetworkId: network.ID,
-		}
+		})
+	}
+
+	for _, company := range show.ProductionCompanies {
+		rows.CompanyRefs = append(rows.CompanyRefs, companyRef(company))
+		rows.Companies = append(rows.Companies, TVShowCompany{
+			ShowId:    show.ID,
+			CompanyId: company.ID,
+		})
 	}
 
 	for _, origCountry := range show.OriginCountries {
-		origCountryCh <- TVShowOrigCountry{
+		rows.OrigCountries = append(rows.OrigCountries, TVShowOrigCountry{
 			ShowId:     show.ID,
 			CountryIso: origCountry,
-		}
+		})
 	}
 
 	for _, prodCountry := range show.ProductionCountries {
-		prodCountryCh <- TVShowProdCountry{
+		rows.ProdCountries = append(rows.ProdCountries, TVShowProdCountry{
 			ShowId:     show.ID,
 			CountryIso: prodCountry.ISO31661,
+		})
+	}
+
+	for _, picked := range translationsByLanguage(show.Translations.Translations, tmdbLanguages()) {
+		translation := picked.Translation
+		name := translation.Data.Name
+		if name == "" {
+			name = show.OriginalName
 		}
+		rows.Translations = append(rows.Translations, TVShowTranslation{
+			ShowId:   show.ID,
+			Language: picked.Language,
+			Name:     name,
+			Overview: filterEmptyString(translation.Data.Overview),
+			Tagline:  filterEmptyString(translation.Data.Tagline),
+		})
+	}
+	rows.WatchProviders = watchProviderRows("tv", show.ID, show.WatchProviders)
+	for _, video := range mediaVideos(show.Videos) {
+		rows.Videos = append(rows.Videos, TVVideo{MediaVideo: video, ShowId: show.ID})
+	}
+	for _, image := range mediaImages(show.Images) {
+		rows.Images = append(rows.Images, TVImage{ShowId: show.ID, MediaImage: image})
+	}
+
+	// Popularity is left out of the hash like for movies.
+	hashed := rows
+	hashed.Base.Popularity = 0
+	hash := contentHash(hashed)
+	if hash != "" && hash == storedContentHash(db, "tv", show.ID) {
+		stats.skipped.Add(1)
+		return
+	}
+
+	if changeLogEnabled() {
+		fetchAndProcessChangesData("tv", show.ID, televisionLimiter, changeLogCh)
+	}
+
+	showBaseCh <- rows.Base
+	seasonCh <- tvShowSeasons{ShowId: show.ID, Seasons: rows.Seasons}
+	for _, genre := range rows.Genres {
+		genreCh <- genre
+	}
+	for _, person := range rows.People {
+		peopleRefCh <- person
+	}
+	for _, creator := range rows.Creators {
+		creatorCh <- creator
+	}
+	actorCh <- tvShowActors{ShowId: show.ID, Actors: rows.Actors}
+	for _, network := range rows.NetworkRefs {
+		networkRefCh <- network
+	}
+	for _, network := range rows.Networks {
+		networkCh <- network
+	}
+	for _, company := range rows.CompanyRefs {
+		companyRefCh <- company
+	}
+	for _, company := range rows.Companies {
+		companyCh <- company
+	}
+	for _, origCountry := range rows.OrigCountries {
+		origCountryCh <- origCountry
+	}
+	for _, prodCountry := range rows.ProdCountries {
+		prodCountryCh <- prodCountry
+	}
+	for _, translation := range rows.Translations {
+		translationCh <- translation
+	}
+	watchProviderCh <- mediaWatchProviders{EntityType: "tv", EntityId: show.ID, Providers: rows.WatchProviders}
+	mediaCh <- tvShowMedia{ShowId: show.ID, Videos: rows.Videos, Images: rows.Images}
+	hashCh <- MediaContentHash{
+		EntityType: "tv",
+		EntityId:   show.ID,
+		Hash:       hash,
+		UpdatedAt:  time.Now(),
 	}
 }
 
-func updateTVShows() {
+func updateTVShows() (*SyncRun, error) {
 	fmt.Printf("Started updating TV Shows at %s \n", time.Now().Format("15:04:05"))
-	username := os.Getenv("POSTGRES_USER")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	host := os.Getenv("POSTGRES_HOST")
-	port := os.Getenv("POSTGRES_PORT")
-	database := os.Getenv("POSTGRES_DATABASE")
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=require TimeZone=Asia/Shanghai",
-		host, username, password, database, port)
-	db, _ := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		PrepareStmt:            true,
-		SkipDefaultTransaction: true,
-	}, nil)
+	db, err := openDB()
+	if err != nil {
+		fmt.Println("Error connecting to the DB:", err)
+		return nil, err
+	}
+	run, err := startSyncRun(db, "tv")
+	if err != nil {
+		return nil, err
+	}
+	var stats syncStats
+	filter := loadContentFilter(db, "tv")
 
 	const batchSize = 500
 	idsCh := make(chan uint32, 10000)
 	showBaseCh := make(chan TVShowBase, 10000)
-	seasonCh := make(chan TVSeasonDB, 100000)
+	seasonCh := make(chan tvShowSeasons, 10000)
 	genreCh := make(chan TVShowGenre, 50000)
-	creatorRefCh := make(chan Creator, 100000)
+	peopleRefCh := make(chan Person, 500000)
 	creatorCh := make(chan TVShowCreator, 100000)
+	actorCh := make(chan tvShowActors, 10000)
 	networkRefCh := make(chan Network, 50000)
 	networkCh := make(chan TVShowNetwork, 50000)
+	companyRefCh := make(chan CinemaCompany, 50000)
+	companyCh := make(chan TVShowCompany, 50000)
 	origCountryCh := make(chan TVShowOrigCountry, 200000)
 	prodCountryCh := make(chan TVShowProdCountry, 200000)
+	hashCh := make(chan MediaContentHash, 10000)
+	changeLogCh := make(chan MediaChangeLog, 100000)
+	translationCh := make(chan TVShowTranslation, 50000)
+	watchProviderCh := make(chan mediaWatchProviders, 10000)
+	mediaCh := make(chan tvShowMedia, 10000)
+	hashesCh := collectContentHashes(hashCh)
 
 	var wgInit sync.WaitGroup
 	wgInit.Add(1)
 	go func() {
 		defer wgInit.Done()
-		fetchAndProcessTVIndexData(1, idsCh)
+		fetchAndProcessTVIndexData(1, filter, &stats, idsCh)
 	}()
 	wgInit.Wait()
 
+	// skippedPage is written before idsCh is closed and read after it drains.
+	var skippedPage uint32
+	checkpoint := loadSyncCheckpoint(db, "tv")
+	if len(checkpoint.PendingIds) > 0 {
+		fmt.Printf("Resuming tv sync with %d pending IDs\n", len(checkpoint.PendingIds))
+	}
+
 	go func() {
-		var wgFetch sync.WaitGroup
-		var i uint16
-		for i = 2; i <= totalPages; i++ {
-			wgFetch.Add(1)
-			go func(i uint16) {
-				defer wgFetch.Done()
-				fetchAndProcessTVIndexData(i, idsCh)
-			}(i)
-		}
-		wgFetch.Wait()
+		for _, id := range checkpoint.PendingIds {
+			idsCh <- uint32(id)
+		}
+		skippedPage = fetchIndexPages(run, 2, totalPages, func(pageNum uint32) {
+			fetchAndProcessTVIndexData(pageNum, filter, &stats, idsCh)
+		})
 		close(idsCh)
 	}()
 
 	go func() {
-		var wgDetails sync.WaitGroup
-		for id := range idsCh {
-			wgDetails.Add(1)
-			go func(id uint32) {
-				defer wgDetails.Done()
-				fetchAndProcessTVDetailsData(id, showBaseCh, seasonCh, genreCh, creatorRefCh, creatorCh, networkRefCh, networkCh, origCountryCh, prodCountryCh)
-			}(id)
-		}
-		wgDetails.Wait()
+		pendingIds := fetchSyncIds(run, televisionLimiter, idsCh, func(id uint32) {
+			fetchAndProcessTVDetailsData(id, db, filter, &stats, hashCh, changeLogCh, showBaseCh, seasonCh, genreCh, peopleRefCh, creatorCh, actorCh, networkRefCh, networkCh, companyRefCh, companyCh, origCountryCh, prodCountryCh, translationCh, watchProviderCh, mediaCh)
+		})
+		markIdsPartial(run, pendingIds, skippedPage)
 		close(showBaseCh)
 		close(seasonCh)
 		close(genreCh)
 		close(creatorCh)
-		close(creatorRefCh)
+		close(actorCh)
+		close(peopleRefCh)
 		close(networkRefCh)
 		close(networkCh)
+		close(companyRefCh)
+		close(companyCh)
 		close(origCountryCh)
 		close(prodCountryCh)
+		close(translationCh)
+		close(watchProviderCh)
+		close(mediaCh)
+		close(hashCh)
+		close(changeLogCh)
+	}()
+
+	// Rows that do not reference a parent row are written while the details
+	// are still fetched, their channels would otherwise fill up and block it.
+	var wgDrain sync.WaitGroup
+	wgDrain.Add(1)
+	go func() {
+		defer wgDrain.Done()
+		writeChangeLogRows(db, changeLogCh, batchSize)
+	}()
+	wgDrain.Add(1)
+	go func() {
+		defer wgDrain.Done()
+		writeWatchProviderRows(db, watchProviderCh, batchSize, &stats)
+	}()
+	wgDrain.Add(1)
+	go func() {
+		defer wgDrain.Done()
+		writeTVMediaRows(db, mediaCh, batchSize, &stats)
 	}()
 
 	var wgWriteBase sync.WaitGroup
 	wgWriteBase.Add(1)
 	go func() {
 		defer wgWriteBase.Done()
-		writeTVBaseRows(db, showBaseCh, batchSize)
+		writeTVBaseRows(db, showBaseCh, batchSize, &stats)
 	}()
+	// Each ref table is drained on its own, the aggregate cast sends every
+	// credited person, so people refs must not wait for the other channels to
+	// close.
 	wgWriteBase.Add(1)
 	go func() {
 		defer wgWriteBase.Done()
-		writeNetworkRefRows(db, networkRefCh, batchSize)
-		writeCreatorRefRows(db, creatorRefCh, batchSize)
+		writeNetworkRefRows(db, networkRefCh, batchSize, &stats)
+	}()
+	wgWriteBase.Add(1)
+	go func() {
+		defer wgWriteBase.Done()
+		writeCompanyRefRows(db, companyRefCh, batchSize, &stats)
+	}()
+	wgWriteBase.Add(1)
+	go func() {
+		defer wgWriteBase.Done()
+		writePeopleRefRows(db, peopleRefCh, batchSize, &stats)
 	}()
 	wgWriteBase.Wait()
 
@@ -394,7 +668,7 @@ func updateTVShows() {
 	wgWriteChild.Add(1)
 	go func() {
 		defer wgWriteChild.Done()
-		writeSeasonRows(db, seasonCh, batchSize)
+		writeSeasonRows(db, seasonCh, batchSize, &stats)
 	}()
 	wgWriteChild.Wait()
 
@@ -402,24 +676,37 @@ func updateTVShows() {
 	wgWriteJoin.Add(1)
 	go func() {
 		defer wgWriteJoin.Done()
-		writeGenreRows(db, genreCh, batchSize)
-		writeCreatorRows(db, creatorCh, batchSize)
-		writeNetworkRows(db, networkCh, batchSize)
-		writeOrigCountryRows(db, origCountryCh, batchSize)
-		writeProdCountryRows(db, prodCountryCh, batchSize)
+		writeGenreRows(db, genreCh, batchSize, &stats)
+		writeCreatorRows(db, creatorCh, batchSize, &stats)
+		writeTVActorRows(db, actorCh, batchSize, &stats)
+		writeNetworkRows(db, networkCh, batchSize, &stats)
+		writeTVCompanyRows(db, companyCh, batchSize, &stats)
+		writeOrigCountryRows(db, origCountryCh, batchSize, &stats)
+		writeProdCountryRows(db, prodCountryCh, batchSize, &stats)
+		writeTVTranslationRows(db, translationCh, batchSize, &stats)
 	}()
 	wgWriteJoin.Wait()
 
+	wgDrain.Wait()
+	writeContentHashRows(db, <-hashesCh, batchSize, &stats)
+	syncWatchProviders(db, "tv", televisionLimiter, &stats)
+	run.Deleted = tombstoneEntities(db, "TVShow", "tv", stats.deletedIds)
+	finishSyncRun(db, run, &stats)
+
 	fmt.Println("Successfully fetched data and written to the DB")
+	return run, nil
 }
 
-func writeTVBaseRows(db *gorm.DB, dataChannel chan TVShowBase, batchSize int) {
+func writeTVBaseRows(db *gorm.DB, dataChannel chan TVShowBase, batchSize int, stats *syncStats) {
 	var batch []TVShowBase
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writeTVBasesBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.ID)
+				}
 			}
 			batch = []TVShowBase{}
 		}
@@ -428,11 +715,28 @@ func writeTVBaseRows(db *gorm.DB, dataChannel chan TVShowBase, batchSize int) {
 	if len(batch) > 0 {
 		if err := writeTVBasesBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.ID)
+			}
 		}
 	}
 }
 func writeTVBasesBatch(db *gorm.DB, objects []TVShowBase) error {
 	return db.Transaction(func(tx *gorm.DB) error {
+		ids := make([]uint32, 0, len(objects))
+		for _, object := range objects {
+			ids = append(ids, object.ID)
+		}
+		payloads, err := createdEntityPayloads(tx, "TVShow", "tv", ids)
+		if err != nil {
+			return err
+		}
+		if err := enqueueWebhookEvents(tx, payloads); err != nil {
+			return err
+		}
+		if err := recordOutboxEvents(tx, "TVShow", "tv", objects); err != nil {
+			return err
+		}
 		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{UpdateAll: true}).Table("TVShow").Model(&TVShowBase{}).Create(&objects).Error; err != nil {
 			return err
 		}
@@ -440,40 +744,76 @@ func writeTVBasesBatch(db *gorm.DB, objects []TVShowBase) error {
 	})
 }
 
-func writeSeasonRows(db *gorm.DB, dataChannel chan TVSeasonDB, batchSize int) {
-	var batch []TVSeasonDB
+func writeSeasonRows(db *gorm.DB, dataChannel chan tvShowSeasons, batchSize int, stats *syncStats) {
+	var batch []tvShowSeasons
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writeSeasonsBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.ShowId)
+				}
 			}
-			batch = []TVSeasonDB{}
+			batch = []tvShowSeasons{}
 		}
 	}
 
 	if len(batch) > 0 {
 		if err := writeSeasonsBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.ShowId)
+			}
 		}
 	}
 }
-func writeSeasonsBatch(db *gorm.DB, objects []TVSeasonDB) error {
+
+// writeSeasonsBatch upserts the seasons of every show in the batch by their
+// TMDB season ID, so renamed seasons and changed air dates or episode counts
+// are applied, and deletes the stored seasons a show no longer has.
+func writeSeasonsBatch(db *gorm.DB, shows []tvShowSeasons) error {
+	showIds := make([]uint32, 0, len(shows))
+	seasonIds := []uint32{}
+	var objects []TVSeasonDB
+	for _, show := range shows {
+		showIds = append(showIds, show.ShowId)
+		for _, season := range show.Seasons {
+			seasonIds = append(seasonIds, season.ID)
+			objects = append(objects, season)
+		}
+	}
 	return db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{DoNothing: true}).Table("TVSeason").Model(&TVSeasonDB{}).Create(&objects).Error; err != nil {
+		stale := tx.Table("TVSeason").Where(`"showId" IN ?`, showIds)
+		if len(seasonIds) > 0 {
+			stale = stale.Where("id NOT IN ?", seasonIds)
+		}
+		if err := stale.Delete(&TVSeasonDB{}).Error; err != nil {
+			return err
+		}
+		if len(objects) == 0 {
+			return nil
+		}
+		if err := recordSeasonAirDateChanges(tx, objects); err != nil {
+			return err
+		}
+		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{UpdateAll: true}).Table("TVSeason").Model(&TVSeasonDB{}).CreateInBatches(&objects, 500).Error; err != nil {
 			return err
 		}
 		return nil
 	})
 }
 
-func writeGenreRows(db *gorm.DB, dataChannel chan TVShowGenre, batchSize int) {
+func writeGenreRows(db *gorm.DB, dataChannel chan TVShowGenre, batchSize int, stats *syncStats) {
 	var batch []TVShowGenre
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writeTVGenresBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.ShowId)
+				}
 			}
 			batch = []TVShowGenre{}
 		}
@@ -482,6 +822,9 @@ func writeGenreRows(db *gorm.DB, dataChannel chan TVShowGenre, batchSize int) {
 	if len(batch) > 0 {
 		if err := writeTVGenresBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.ShowId)
+			}
 		}
 	}
 }
@@ -494,67 +837,93 @@ func writeTVGenresBatch(db *gorm.DB, objects []TVShowGenre) error {
 	})
 }
 
-func writeCreatorRefRows(db *gorm.DB, dataChannel chan Creator, batchSize int) {
-	var batch []Creator
+func writeCreatorRows(db *gorm.DB, dataChannel chan TVShowCreator, batchSize int, stats *syncStats) {
+	var batch []TVShowCreator
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
-			if err := writeCreatorRefsBatch(db, batch); err != nil {
+			if err := writeCreatorsBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.ShowId)
+				}
 			}
-			batch = []Creator{}
+			batch = []TVShowCreator{}
 		}
 	}
 
 	if len(batch) > 0 {
-		if err := writeCreatorRefsBatch(db, batch); err != nil {
+		if err := writeCreatorsBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.ShowId)
+			}
 		}
 	}
 }
-func writeCreatorRefsBatch(db *gorm.DB, objects []Creator) error {
+func writeCreatorsBatch(db *gorm.DB, objects []TVShowCreator) error {
 	return db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{DoNothing: true}).Table("CinemaPerson").Model(&Creator{}).Create(&objects).Error; err != nil {
+		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{DoNothing: true}).Table("TVShowCreator").Model(&TVShowCreator{}).Create(&objects).Error; err != nil {
 			return err
 		}
 		return nil
 	})
 }
 
-func writeCreatorRows(db *gorm.DB, dataChannel chan TVShowCreator, batchSize int) {
-	var batch []TVShowCreator
+func writeTVActorRows(db *gorm.DB, dataChannel chan tvShowActors, batchSize int, stats *syncStats) {
+	var batch []tvShowActors
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
-			if err := writeCreatorsBatch(db, batch); err != nil {
+			if err := writeTVActorsBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.ShowId)
+				}
 			}
-			batch = []TVShowCreator{}
+			batch = []tvShowActors{}
 		}
 	}
 
 	if len(batch) > 0 {
-		if err := writeCreatorsBatch(db, batch); err != nil {
+		if err := writeTVActorsBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.ShowId)
+			}
 		}
 	}
 }
-func writeCreatorsBatch(db *gorm.DB, objects []TVShowCreator) error {
+
+// writeTVActorsBatch replaces the cast of every show in the batch.
+func writeTVActorsBatch(db *gorm.DB, objects []tvShowActors) error {
+	ids := make([]uint32, 0, len(objects))
+	var actors []TVShowActor
+	for _, object := range objects {
+		ids = append(ids, object.ShowId)
+		actors = append(actors, object.Actors...)
+	}
 	return db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{DoNothing: true}).Table("TVShowCreator").Model(&TVShowCreator{}).Create(&objects).Error; err != nil {
+		if err := tx.Table("TVShowActor").Where(`"showId" IN ?`, ids).Delete(&TVShowActor{}).Error; err != nil {
 			return err
 		}
+		if len(actors) > 0 {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Table("TVShowActor").CreateInBatches(&actors, 1000).Error; err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 }
 
-func writeNetworkRefRows(db *gorm.DB, dataChannel chan Network, batchSize int) {
+func writeNetworkRefRows(db *gorm.DB, dataChannel chan Network, batchSize int, stats *syncStats) {
 	var batch []Network
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writeNetworkRefsBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				stats.markRefsFailed()
 			}
 			batch = []Network{}
 		}
@@ -563,6 +932,7 @@ func writeNetworkRefRows(db *gorm.DB, dataChannel chan Network, batchSize int) {
 	if len(batch) > 0 {
 		if err := writeNetworkRefsBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			stats.markRefsFailed()
 		}
 	}
 }
@@ -575,13 +945,16 @@ func writeNetworkRefsBatch(db *gorm.DB, objects []Network) error {
 	})
 }
 
-func writeNetworkRows(db *gorm.DB, dataChannel chan TVShowNetwork, batchSize int) {
+func writeNetworkRows(db *gorm.DB, dataChannel chan TVShowNetwork, batchSize int, stats *syncStats) {
 	var batch []TVShowNetwork
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writeNetworksBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.ShowId)
+				}
 			}
 			batch = []TVShowNetwork{}
 		}
@@ -590,6 +963,9 @@ func writeNetworkRows(db *gorm.DB, dataChannel chan TVShowNetwork, batchSize int
 	if len(batch) > 0 {
 		if err := writeNetworksBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.ShowId)
+			}
 		}
 	}
 }
@@ -602,13 +978,49 @@ func writeNetworksBatch(db *gorm.DB, objects []TVShowNetwork) error {
 	})
 }
 
-func writeOrigCountryRows(db *gorm.DB, dataChannel chan TVShowOrigCountry, batchSize int) {
+func writeTVCompanyRows(db *gorm.DB, dataChannel chan TVShowCompany, batchSize int, stats *syncStats) {
+	var batch []TVShowCompany
+	for entry := range dataChannel {
+		batch = append(batch, entry)
+		if len(batch) >= batchSize {
+			if err := writeTVCompaniesBatch(db, batch); err != nil {
+				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.ShowId)
+				}
+			}
+			batch = []TVShowCompany{}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := writeTVCompaniesBatch(db, batch); err != nil {
+			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.ShowId)
+			}
+		}
+	}
+}
+func writeTVCompaniesBatch(db *gorm.DB, objects []TVShowCompany) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{DoNothing: true}).Table("TVShowCompany").Model(&TVShowCompany{}).Create(&objects).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func writeOrigCountryRows(db *gorm.DB, dataChannel chan TVShowOrigCountry, batchSize int, stats *syncStats) {
 	var batch []TVShowOrigCountry
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writeOrigCountriesBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.ShowId)
+				}
 			}
 			batch = []TVShowOrigCountry{}
 		}
@@ -617,6 +1029,9 @@ func writeOrigCountryRows(db *gorm.DB, dataChannel chan TVShowOrigCountry, batch
 	if len(batch) > 0 {
 		if err := writeOrigCountriesBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.ShowId)
+			}
 		}
 	}
 }
@@ -629,13 +1044,16 @@ func writeOrigCountriesBatch(db *gorm.DB, objects []TVShowOrigCountry) error {
 	})
 }
 
-func writeProdCountryRows(db *gorm.DB, dataChannel chan TVShowProdCountry, batchSize int) {
+func writeProdCountryRows(db *gorm.DB, dataChannel chan TVShowProdCountry, batchSize int, stats *syncStats) {
 	var batch []TVShowProdCountry
 	for entry := range dataChannel {
 		batch = append(batch, entry)
 		if len(batch) >= batchSize {
 			if err := writeProdCountriesBatch(db, batch); err != nil {
 				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.ShowId)
+				}
 			}
 			batch = []TVShowProdCountry{}
 		}
@@ -644,6 +1062,9 @@ func writeProdCountryRows(db *gorm.DB, dataChannel chan TVShowProdCountry, batch
 	if len(batch) > 0 {
 		if err := writeProdCountriesBatch(db, batch); err != nil {
 			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.ShowId)
+			}
 		}
 	}
 }
@@ -655,3 +1076,94 @@ func writeProdCountriesBatch(db *gorm.DB, objects []TVShowProdCountry) error {
 		return nil
 	})
 }
+
+func writeTVTranslationRows(db *gorm.DB, dataChannel chan TVShowTranslation, batchSize int, stats *syncStats) {
+	var batch []TVShowTranslation
+	for entry := range dataChannel {
+		batch = append(batch, entry)
+		if len(batch) >= batchSize {
+			if err := writeTVTranslationsBatch(db, batch); err != nil {
+				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.ShowId)
+				}
+			}
+			batch = []TVShowTranslation{}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := writeTVTranslationsBatch(db, batch); err != nil {
+			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.ShowId)
+			}
+		}
+	}
+}
+
+func writeTVTranslationsBatch(db *gorm.DB, objects []TVShowTranslation) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.WithContext(context.Background()).Clauses(clause.OnConflict{UpdateAll: true}).Table("TVShowTranslation").Model(&TVShowTranslation{}).Create(&objects).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func writeTVMediaRows(db *gorm.DB, dataChannel chan tvShowMedia, batchSize int, stats *syncStats) {
+	var batch []tvShowMedia
+	for entry := range dataChannel {
+		batch = append(batch, entry)
+		if len(batch) >= batchSize {
+			if err := writeTVMediaBatch(db, batch); err != nil {
+				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.ShowId)
+				}
+			}
+			batch = []tvShowMedia{}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := writeTVMediaBatch(db, batch); err != nil {
+			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.ShowId)
+			}
+		}
+	}
+}
+
+// writeTVMediaBatch replaces the videos and images of every show in the
+// batch, dropping the ones TMDB no longer lists.
+func writeTVMediaBatch(db *gorm.DB, objects []tvShowMedia) error {
+	ids := make([]uint32, 0, len(objects))
+	var videos []TVVideo
+	var images []TVImage
+	for _, object := range objects {
+		ids = append(ids, object.ShowId)
+		videos = append(videos, object.Videos...)
+		images = append(images, object.Images...)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("TVVideo").Where(`"showId" IN ?`, ids).Delete(&TVVideo{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("TVImage").Where(`"showId" IN ?`, ids).Delete(&TVImage{}).Error; err != nil {
+			return err
+		}
+		if len(videos) > 0 {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Table("TVVideo").CreateInBatches(&videos, 1000).Error; err != nil {
+				return err
+			}
+		}
+		if len(images) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Table("TVImage").CreateInBatches(&images, 1000).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
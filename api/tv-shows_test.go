@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func loadTVShowFixture(t *testing.T, fixture string) TVShow {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var show TVShow
+	var stats syncStats
+	if err := decodeIngest(body, &show, fixture, &stats); err != nil {
+		t.Fatal(err)
+	}
+	return show
+}
+
+func TestTVSeasonRows(t *testing.T) {
+	tests := []struct {
+		fixture      string
+		wantSeasons  int
+		wantNumbers  [2]uint16
+		wantUndated  int
+		wantSpecials uint32
+	}{
+		{fixture: "tv-specials.json", wantSeasons: 5, wantNumbers: [2]uint16{0, 6}, wantUndated: 2, wantSpecials: 74451},
+		{fixture: "tv-many-seasons.json", wantSeasons: 37, wantNumbers: [2]uint16{0, 36}, wantSpecials: 3568},
+	}
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			show := loadTVShowFixture(t, test.fixture)
+			seasons := tvSeasonRows(show)
+			if len(seasons) != test.wantSeasons {
+				t.Fatalf("got %d seasons, want %d", len(seasons), test.wantSeasons)
+			}
+			if first, last := seasons[0].SeasonNumber, seasons[len(seasons)-1].SeasonNumber; first != test.wantNumbers[0] || last != test.wantNumbers[1] {
+				t.Errorf("seasons %d to %d, want %d to %d", first, last, test.wantNumbers[0], test.wantNumbers[1])
+			}
+			if seasons[0].ID != test.wantSpecials {
+				t.Errorf("specials ID = %d, want %d", seasons[0].ID, test.wantSpecials)
+			}
+
+			ids := map[uint32]bool{}
+			undated := 0
+			for _, season := range seasons {
+				if season.ShowID != show.ID {
+					t.Errorf("season %d has show ID %d, want %d", season.SeasonNumber, season.ShowID, show.ID)
+				}
+				if season.ID == show.ID {
+					t.Errorf("season %d is keyed on the show ID", season.SeasonNumber)
+				}
+				if ids[season.ID] {
+					t.Errorf("season ID %d used twice", season.ID)
+				}
+				ids[season.ID] = true
+				if season.AirDate == nil {
+					undated++
+				}
+			}
+			if undated != test.wantUndated {
+				t.Errorf("%d seasons without an air date, want %d", undated, test.wantUndated)
+			}
+		})
+	}
+}
+
+func TestSeasonAirDateChanges(t *testing.T) {
+	date := func(value string) *time.Time {
+		return parseAirDate(&value)
+	}
+	seasons := tvSeasonRows(loadTVShowFixture(t, "tv-specials.json"))
+	existing := map[uint32]*time.Time{
+		// A row left from when seasons were keyed on the show ID.
+		19885:  date("2010-07-25"),
+		74451:  date("2015-12-25"),
+		54297:  date("2010-07-25"),
+		215683: nil,
+		215684: date("2027-01-01"),
+	}
+	now := time.Now()
+
+	changes, payloads := seasonAirDateChanges(seasons, existing, now)
+
+	wantChanges := map[uint32]bool{74451: true, 215684: true}
+	if len(changes) != len(wantChanges) {
+		t.Errorf("got %d changes, want %d", len(changes), len(wantChanges))
+	}
+	for _, change := range changes {
+		if !wantChanges[change.ReleaseId] {
+			t.Errorf("unexpected change for season %d", change.ReleaseId)
+		}
+		if change.EntityId != 19885 || change.SeasonNumber == nil || !change.ObservedAt.Equal(now) {
+			t.Errorf("got %+v, want show 19885 with its season number", change)
+		}
+	}
+
+	// Series 2 is new to the table and dated, the specials moved. Series 6
+	// lost its date, which is recorded but not announced.
+	wantEvents := map[uint32]string{54298: "release_date.dated", 74451: "release_date.changed"}
+	if len(payloads) != len(wantEvents) {
+		t.Errorf("got %d payloads, want %d", len(payloads), len(wantEvents))
+	}
+	for _, payload := range payloads {
+		if payload.ReleaseId == nil || wantEvents[*payload.ReleaseId] != payload.Event {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+	}
+}
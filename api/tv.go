@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+type TVShowDetail struct {
+	ID                     uint32          `json:"id"`
+	Name                   string          `json:"name"`
+	Language               *string         `json:"language"`
+	Overview               *string         `json:"overview"`
+	Tagline                *string         `json:"tagline"`
+	CreatedBy              []Person        `json:"created_by"`
+	Cast                   []TVCast        `json:"cast"`
+	EpisodeRunTimes        []int32         `json:"episode_run_time"`
+	FirstAirDate           *string         `json:"first_air_date"`
+	LastAirDate            *string         `json:"last_air_date"`
+	GenreIds               []uint32        `json:"genre_ids"`
+	InProduction           bool            `json:"in_production"`
+	Languages              []string        `json:"languages"`
+	Networks               []Network       `json:"networks"`
+	OriginCountries        []string        `json:"origin_country"`
+	OriginalLanguage       string          `json:"original_language"`
+	OriginalName           string          `json:"original_name"`
+	Popularity             float32         `json:"popularity"`
+	PosterPath             *string         `json:"poster_path"`
+	ProductionCountryCodes []string        `json:"production_country_codes"`
+	ProductionCompanies    []CinemaCompany `json:"production_companies"`
+	Seasons                []TVSeason      `json:"seasons"`
+	Status                 string          `json:"status"`
+	Type                   string          `json:"type"`
+	VoteAverage            float32         `json:"vote_average"`
+	Videos                 []TVVideo       `json:"videos"`
+	Images                 []TVImage       `json:"images"`
+}
+
+// TVCast is a person from TMDB's aggregate cast. It lists series regulars and
+// guest stars alike, guest stars of single episodes are not stored apart and
+// only show through a low episode count.
+type TVCast struct {
+	ID           uint32         `json:"id"`
+	Name         string         `json:"name"`
+	Characters   pq.StringArray `json:"characters" gorm:"type:text[]"`
+	EpisodeCount uint16         `json:"episode_count" gorm:"column:episodeCount"`
+}
+
+// TVShowDetails returns a single TV show with its seasons, creators, cast,
+// networks, production companies, countries, videos and images. JSON field
+// names follow the ingest structs. With ?language= the name, overview and
+// tagline come from that translation, and the name falls back to the original
+// name.
+func TVShowDetails(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeRead) {
+		return
+	}
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Missing or invalid id", http.StatusBadRequest)
+		return
+	}
+	db, err := openDB()
+	if err != nil {
+		http.Error(w, "Error connecting to the DB", http.StatusInternalServerError)
+		return
+	}
+
+	var base []TVShowBase
+	if err := db.Table("TVShow").Where(`id = ? AND "deletedAt" IS NULL`, id).Limit(1).Find(&base).Error; err != nil {
+		http.Error(w, "Error reading TV show", http.StatusInternalServerError)
+		return
+	}
+	if len(base) == 0 {
+		http.Error(w, "TV show not found", http.StatusNotFound)
+		return
+	}
+
+	detail, err := loadTVShowDetail(db, base[0])
+	if err != nil {
+		http.Error(w, "Error reading TV show relations", http.StatusInternalServerError)
+		return
+	}
+	if language := r.URL.Query().Get("language"); language != "" {
+		if err := applyTVShowTranslation(db, &detail, language); err != nil {
+			http.Error(w, "Error reading TV show translation", http.StatusInternalServerError)
+			return
+		}
+	}
+	writeCachedJSON(w, r, detail)
+}
+
+func normalizeDatePtr(input *string) *string {
+	if input == nil {
+		return nil
+	}
+	result := normalizeDateString(*input)
+	return &result
+}
+
+func loadTVShowDetail(db *gorm.DB, show TVShowBase) (TVShowDetail, error) {
+	detail := TVShowDetail{
+		ID:                     show.ID,
+		Name:                   show.Name,
+		EpisodeRunTimes:        show.EpisodeRunTimes,
+		FirstAirDate:           normalizeDatePtr(show.FirstAirDate),
+		LastAirDate:            normalizeDatePtr(show.LastAirDate),
+		GenreIds:               []uint32{},
+		InProduction:           show.InProduction,
+		Languages:              show.Languages,
+		Networks:               []Network{},
+		OriginCountries:        []string{},
+		OriginalLanguage:       show.OriginalLanguage,
+		OriginalName:           show.OriginalName,
+		Popularity:             show.Popularity,
+		PosterPath:             show.PosterPath,
+		ProductionCountryCodes: []string{},
+		Seasons:                []TVSeason{},
+		Status:                 show.Status,
+		Type:                   show.Type,
+		VoteAverage:            show.VoteAverage,
+		Cast:                   []TVCast{},
+		Videos:                 []TVVideo{},
+		Images:                 []TVImage{},
+	}
+
+	var err error
+	if detail.CreatedBy, err = loadCinemaPeople(db, "TVShowCreator", "creatorId", "showId", show.ID); err != nil {
+		return detail, err
+	}
+	err = db.Table(`"TVShowActor" AS a`).
+		Select(`p.id, p.name, a.characters, a."episodeCount"`).
+		Joins(`JOIN "CinemaPerson" p ON p.id = a."actorId"`).
+		Where(`a."showId" = ?`, show.ID).
+		Order("a.position").
+		Scan(&detail.Cast).Error
+	if err != nil {
+		return detail, err
+	}
+	err = db.Table("TVNetwork").
+		Where(`id IN (SELECT "networkId" FROM "TVShowNetwork" WHERE "showId" = ?)`, show.ID).
+		Order("id").
+		Find(&detail.Networks).Error
+	if err != nil {
+		return detail, err
+	}
+	if err := db.Table("TVShowGenre").Where(`"showId" = ?`, show.ID).Order(`"genreId"`).Pluck(`"genreId"`, &detail.GenreIds).Error; err != nil {
+		return detail, err
+	}
+	if err := db.Table("TVShowOrigCountry").Where(`"showId" = ?`, show.ID).Order(`"countryIso"`).Pluck(`"countryIso"`, &detail.OriginCountries).Error; err != nil {
+		return detail, err
+	}
+	if err := db.Table("TVShowProdCountry").Where(`"showId" = ?`, show.ID).Order(`"countryIso"`).Pluck(`"countryIso"`, &detail.ProductionCountryCodes).Error; err != nil {
+		return detail, err
+	}
+	if detail.ProductionCompanies, err = loadCinemaCompanies(db, "TVShowCompany", "showId", show.ID); err != nil {
+		return detail, err
+	}
+
+	if err := db.Table("TVVideo").Where(`"showId" = ?`, show.ID).Order(`official DESC, "publishedAt" DESC`).Find(&detail.Videos).Error; err != nil {
+		return detail, err
+	}
+	if err := db.Table("TVImage").Where(`"showId" = ?`, show.ID).Order(`type, "voteAverage" DESC`).Find(&detail.Images).Error; err != nil {
+		return detail, err
+	}
+
+	var seasons []TVSeasonDB
+	if err := db.Table("TVSeason").Where(`"showId" = ?`, show.ID).Order(`"seasonNumber"`).Find(&seasons).Error; err != nil {
+		return detail, err
+	}
+	for _, season := range seasons {
+		item := TVSeason{
+			ID:           season.ID,
+			Name:         season.Name,
+			SeasonNumber: season.SeasonNumber,
+			PosterPath:   season.PosterPath,
+			EpisodeCount: season.EpisodeCount,
+			VoteAverage:  season.VoteAverage,
+		}
+		if season.AirDate != nil {
+			item.AirDate = normalizeDateString(*season.AirDate)
+		}
+		detail.Seasons = append(detail.Seasons, item)
+	}
+
+	return detail, nil
+}
+
+func applyTVShowTranslation(db *gorm.DB, detail *TVShowDetail, language string) error {
+	var translations []TVShowTranslation
+	if err := db.Table("TVShowTranslation").Where(`"showId" = ? AND language = ?`, detail.ID, language).Limit(1).Find(&translations).Error; err != nil {
+		return err
+	}
+	detail.Language = &language
+	if len(translations) == 0 {
+		detail.Name = detail.OriginalName
+		return nil
+	}
+	detail.Name = translations[0].Name
+	detail.Overview = translations[0].Overview
+	detail.Tagline = translations[0].Tagline
+	return nil
+}
@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WatchProviderResults struct {
+	Results map[string]WatchProviderRegion `json:"results"`
+}
+
+type WatchProviderRegion struct {
+	Link     string              `json:"link"`
+	Flatrate []WatchProviderData `json:"flatrate"`
+	Rent     []WatchProviderData `json:"rent"`
+	Buy      []WatchProviderData `json:"buy"`
+	Free     []WatchProviderData `json:"free"`
+	Ads      []WatchProviderData `json:"ads"`
+}
+
+type WatchProviderData struct {
+	ProviderId      uint32  `json:"provider_id"`
+	ProviderName    string  `json:"provider_name"`
+	LogoPath        *string `json:"logo_path"`
+	DisplayPriority uint16  `json:"display_priority"`
+}
+
+type WatchProviderList struct {
+	Results []WatchProviderData `json:"results"`
+}
+
+// DB structs
+
+type WatchProvider struct {
+	ID              uint32    `json:"id" gorm:"primaryKey;autoIncrement:false"`
+	Name            string    `json:"name"`
+	LogoPath        *string   `json:"logo_path" gorm:"column:logoPath"`
+	DisplayPriority uint16    `json:"display_priority" gorm:"column:displayPriority"`
+	UpdatedAt       time.Time `json:"-" gorm:"column:updatedAt"`
+}
+
+type MediaWatchProvider struct {
+	EntityType      string  `json:"-" gorm:"column:entityType;primaryKey"`
+	EntityId        uint32  `json:"-" gorm:"column:entityId;primaryKey;autoIncrement:false"`
+	Region          string  `json:"region" gorm:"primaryKey"`
+	Type            string  `json:"type" gorm:"primaryKey"`
+	ProviderId      uint32  `json:"provider_id" gorm:"column:providerId;primaryKey;autoIncrement:false;index:idx_media_watch_provider_provider"`
+	DisplayPriority uint16  `json:"display_priority" gorm:"column:displayPriority"`
+	Link            *string `json:"link"`
+}
+
+// mediaWatchProviders carries every provider row of one title, so the writer
+// can replace the title's availability as a whole.
+type mediaWatchProviders struct {
+	EntityType string
+	EntityId   uint32
+	Providers  []MediaWatchProvider
+}
+
+type WatchProviderOffer struct {
+	WatchProvider
+	Type string `json:"type"`
+}
+
+type WatchProviderAvailability struct {
+	Region    string               `json:"region"`
+	Link      *string              `json:"link"`
+	Providers []WatchProviderOffer `json:"providers"`
+}
+
+// WatchProviders lists where a title streams, rents or sells per region with
+// ?type=movie|tv&id= (optionally &region=), or the provider reference list of
+// a media type without an id.
+func WatchProviders(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeRead) {
+		return
+	}
+	mediaType := r.URL.Query().Get("type")
+	if mediaType != "movie" && mediaType != "tv" {
+		http.Error(w, "type must be movie or tv", http.StatusBadRequest)
+		return
+	}
+	db, err := openDB()
+	if err != nil {
+		http.Error(w, "Error connecting to the DB", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("id") == "" {
+		providers := []WatchProvider{}
+		err := db.Table("WatchProvider").
+			Where(`id IN (SELECT DISTINCT "providerId" FROM "MediaWatchProvider" WHERE "entityType" = ?)`, mediaType).
+			Order(`"displayPriority", name`).
+			Find(&providers).Error
+		if err != nil {
+			http.Error(w, "Error reading watch providers", http.StatusInternalServerError)
+			return
+		}
+		writeCachedJSON(w, r, providers)
+		return
+	}
+
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+	availability, err := loadWatchProviderAvailability(db, mediaType, uint32(id), strings.ToUpper(r.URL.Query().Get("region")))
+	if err != nil {
+		http.Error(w, "Error reading watch providers", http.StatusInternalServerError)
+		return
+	}
+	writeCachedJSON(w, r, availability)
+}
+
+func loadWatchProviderAvailability(db *gorm.DB, mediaType string, id uint32, region string) ([]WatchProviderAvailability, error) {
+	var rows []struct {
+		MediaWatchProvider
+		Name     string
+		LogoPath *string `gorm:"column:logoPath"`
+	}
+	query := db.Table(`"MediaWatchProvider" AS m`).
+		Select(`m.*, p.name, p."logoPath"`).
+		Joins(`LEFT JOIN "WatchProvider" p ON p.id = m."providerId"`).
+		Where(`m."entityType" = ? AND m."entityId" = ?`, mediaType, id)
+	if region != "" {
+		query = query.Where("m.region = ?", region)
+	}
+	if err := query.Order(`m.region, m.type, m."displayPriority"`).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	availability := []WatchProviderAvailability{}
+	for _, row := range rows {
+		if len(availability) == 0 || availability[len(availability)-1].Region != row.Region {
+			availability = append(availability, WatchProviderAvailability{Region: row.Region, Link: row.Link})
+		}
+		current := &availability[len(availability)-1]
+		current.Providers = append(current.Providers, WatchProviderOffer{
+			WatchProvider: WatchProvider{
+				ID:              row.ProviderId,
+				Name:            row.Name,
+				LogoPath:        row.LogoPath,
+				DisplayPriority: row.DisplayPriority,
+			},
+			Type: row.Type,
+		})
+	}
+	return availability, nil
+}
+
+// tmdbWatchRegions returns the regions to store watch providers for, from the
+// comma-separated TMDB_WATCH_REGIONS (e.g. "US,DE"). Empty means every region.
+func tmdbWatchRegions() map[string]bool {
+	regions := map[string]bool{}
+	for _, region := range strings.Split(os.Getenv("TMDB_WATCH_REGIONS"), ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			regions[strings.ToUpper(region)] = true
+		}
+	}
+	return regions
+}
+
+// watchProviderRows flattens the append_to_response watch providers of a
+// title in a stable order, so unchanged availability keeps its content hash.
+func watchProviderRows(entityType string, id uint32, results WatchProviderResults) []MediaWatchProvider {
+	allowed := tmdbWatchRegions()
+	regions := make([]string, 0, len(results.Results))
+	for region := range results.Results {
+		if len(allowed) == 0 || allowed[region] {
+			regions = append(regions, region)
+		}
+	}
+	sort.Strings(regions)
+
+	var rows []MediaWatchProvider
+	for _, region := range regions {
+		offers := results.Results[region]
+		for _, offer := range []struct {
+			Type      string
+			Providers []WatchProviderData
+		}{
+			{"flatrate", offers.Flatrate},
+			{"rent", offers.Rent},
+			{"buy", offers.Buy},
+			{"free", offers.Free},
+			{"ads", offers.Ads},
+		} {
+			for _, provider := range offer.Providers {
+				rows = append(rows, MediaWatchProvider{
+					EntityType:      entityType,
+					EntityId:        id,
+					Region:          region,
+					Type:            offer.Type,
+					ProviderId:      provider.ProviderId,
+					DisplayPriority: provider.DisplayPriority,
+					Link:            filterEmptyString(offers.Link),
+				})
+			}
+		}
+	}
+	return rows
+}
+
+func fetchWatchProviderListData(mediaType string, limiter *rate.Limiter) ([]byte, error) {
+	if err := limiter.Wait(context.Background()); err != nil {
+		fmt.Printf("Rate limit exceeded for %s watch providers: %v\n", mediaType, err)
+	}
+
+	url := fmt.Sprintf("https://api.themoviedb.org/3/watch/providers/%s?language=en-US", mediaType)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("API_ACCESS_TOKEN"))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status code: %d", res.StatusCode)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// syncWatchProviders refreshes the provider reference list of a media type.
+func syncWatchProviders(db *gorm.DB, mediaType string, limiter *rate.Limiter, stats *syncStats) {
+	body, err := fetchWatchProviderListData(mediaType, limiter)
+	if err != nil {
+		fmt.Printf("Error fetching %s watch providers: %v\n", mediaType, err)
+		return
+	}
+	var list WatchProviderList
+	if err := decodeIngest(body, &list, mediaType+" watch providers", stats); err != nil {
+		fmt.Printf("Error parsing %s watch providers: %v\n", mediaType, err)
+		return
+	}
+	if len(list.Results) == 0 {
+		return
+	}
+	providers := make([]WatchProvider, 0, len(list.Results))
+	for _, provider := range list.Results {
+		providers = append(providers, WatchProvider{
+			ID:              provider.ProviderId,
+			Name:            provider.ProviderName,
+			LogoPath:        provider.LogoPath,
+			DisplayPriority: provider.DisplayPriority,
+			UpdatedAt:       time.Now(),
+		})
+	}
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Table("WatchProvider").CreateInBatches(&providers, 500).Error; err != nil {
+		fmt.Printf("Error writing %s watch providers: %v\n", mediaType, err)
+	}
+}
+
+func writeWatchProviderRows(db *gorm.DB, dataChannel chan mediaWatchProviders, batchSize int, stats *syncStats) {
+	var batch []mediaWatchProviders
+	for entry := range dataChannel {
+		batch = append(batch, entry)
+		if len(batch) >= batchSize {
+			if err := writeWatchProvidersBatch(db, batch); err != nil {
+				fmt.Println("Error writing batch:", err)
+				for _, entry := range batch {
+					stats.markFailed(entry.EntityId)
+				}
+			}
+			batch = []mediaWatchProviders{}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := writeWatchProvidersBatch(db, batch); err != nil {
+			fmt.Println("Error writing final batch:", err)
+			for _, entry := range batch {
+				stats.markFailed(entry.EntityId)
+			}
+		}
+	}
+}
+
+// writeWatchProvidersBatch replaces the availability of every title in the
+// batch, dropping providers a title is no longer offered on.
+func writeWatchProvidersBatch(db *gorm.DB, objects []mediaWatchProviders) error {
+	ids := map[string][]uint32{}
+	var rows []MediaWatchProvider
+	for _, object := range objects {
+		ids[object.EntityType] = append(ids[object.EntityType], object.EntityId)
+		rows = append(rows, object.Providers...)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for entityType, entityIds := range ids {
+			if err := tx.Table("MediaWatchProvider").Where(`"entityType" = ? AND "entityId" IN ?`, entityType, entityIds).Delete(&MediaWatchProvider{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Table("MediaWatchProvider").CreateInBatches(&rows, 1000).Error
+	})
+}
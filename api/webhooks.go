@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type WebhookPayload struct {
+	Event      string     `json:"event"`
+	EntityType string     `json:"entity_type"`
+	EntityId   uint32     `json:"entity_id"`
+	ReleaseId  *uint32    `json:"release_id,omitempty"`
+	Region     *string    `json:"region,omitempty"`
+	PlatformId *uint16    `json:"platform_id,omitempty"`
+	OldDate    *time.Time `json:"old_date,omitempty"`
+	NewDate    *time.Time `json:"new_date,omitempty"`
+	OccurredAt time.Time  `json:"occurred_at"`
+}
+
+type WebhookDelivery struct {
+	ID            uint32 `gorm:"primaryKey;autoIncrement"`
+	Url           string
+	Event         string
+	Payload       string     `gorm:"type:jsonb"`
+	Attempts      uint16     `gorm:"default:0"`
+	NextAttemptAt *time.Time `gorm:"column:nextAttemptAt;index"`
+	DeliveredAt   *time.Time `gorm:"column:deliveredAt"`
+	LastError     *string    `gorm:"column:lastError"`
+	CreatedAt     time.Time  `gorm:"column:createdAt"`
+}
+
+const (
+	maxWebhookAttempts = 10
+	// Deliveries are claimed this many at a time by moving nextAttemptAt past
+	// the claim timeout, so concurrent deliverers skip them and the ones a
+	// crashed deliverer held are retried afterwards.
+	webhookClaimSize    = 50
+	webhookClaimTimeout = 5 * time.Minute
+	// Delivery stops early enough to record the outcome within the 300s
+	// function limit.
+	webhookDeliveryBudget = 280 * time.Second
+)
+
+var (
+	webhookClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+func Webhooks(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, scopeSync) {
+		return
+	}
+	db, err := openDB()
+	if err != nil {
+		http.Error(w, "Error connecting to the DB", http.StatusInternalServerError)
+		return
+	}
+	delivered, failed := deliverWebhooks(db, time.Now().Add(webhookDeliveryBudget))
+	fmt.Fprintf(w, "Delivered %d webhooks, %d failed", delivered, failed)
+}
+
+func webhookUrls() []string {
+	var urls []string
+	for _, url := range strings.Split(os.Getenv("WEBHOOK_URLS"), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+func signWebhookPayload(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("WEBHOOK_SECRET")))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func enqueueWebhookEvents(tx *gorm.DB, payloads []WebhookPayload) error {
+	urls := webhookUrls()
+	if len(urls) == 0 || len(payloads) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var deliveries []WebhookDelivery
+	for _, payload := range payloads {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		for _, url := range urls {
+			deliveries = append(deliveries, WebhookDelivery{
+				Url:           url,
+				Event:         payload.Event,
+				Payload:       string(body),
+				NextAttemptAt: &now,
+				CreatedAt:     now,
+			})
+		}
+	}
+	return tx.Table("WebhookOutbox").Create(&deliveries).Error
+}
+
+func createdEntityPayloads(tx *gorm.DB, table string, entityType string, ids []uint32) ([]WebhookPayload, error) {
+	var existingIds []uint32
+	if err := tx.Table(table).Where("id IN ?", ids).Pluck("id", &existingIds).Error; err != nil {
+		return nil, err
+	}
+	existing := make(map[uint32]bool, len(existingIds))
+	for _, id := range existingIds {
+		existing[id] = true
+	}
+
+	now := time.Now()
+	var payloads []WebhookPayload
+	for _, id := range ids {
+		if !existing[id] {
+			payloads = append(payloads, WebhookPayload{
+				Event:      entityType + ".created",
+				EntityType: entityType,
+				EntityId:   id,
+				OccurredAt: now,
+			})
+		}
+	}
+	return payloads, nil
+}
+
+func releaseDatePayload(event string, change ReleaseDateChange) WebhookPayload {
+	releaseId := change.ReleaseId
+	return WebhookPayload{
+		Event:      event,
+		EntityType: change.EntityType,
+		EntityId:   change.EntityId,
+		ReleaseId:  &releaseId,
+		Region:     change.Region,
+		PlatformId: change.PlatformId,
+		OldDate:    change.OldDate,
+		NewDate:    change.NewDate,
+		OccurredAt: change.ObservedAt,
+	}
+}
+
+// deliverWebhooks sends due deliveries until none are left or the next one
+// could not finish before deadline.
+func deliverWebhooks(db *gorm.DB, deadline time.Time) (delivered int, failed int) {
+	for time.Now().Add(webhookClient.Timeout).Before(deadline) {
+		deliveries, err := claimWebhookDeliveries(db)
+		if err != nil {
+			fmt.Println("Error reading webhook outbox:", err)
+			return delivered, failed
+		}
+		if len(deliveries) == 0 {
+			return delivered, failed
+		}
+
+		for i, delivery := range deliveries {
+			if !time.Now().Add(webhookClient.Timeout).Before(deadline) {
+				unclaimWebhookDeliveries(db, deliveries[i:])
+				return delivered, failed
+			}
+			if recordWebhookAttempt(&delivery, sendWebhook(delivery), time.Now()) {
+				delivered++
+			} else {
+				failed++
+			}
+			if err := db.Table("WebhookOutbox").Save(&delivery).Error; err != nil {
+				fmt.Println("Error updating webhook outbox:", err)
+			}
+		}
+	}
+	return delivered, failed
+}
+
+func claimWebhookDeliveries(db *gorm.DB) ([]WebhookDelivery, error) {
+	now := time.Now()
+	var deliveries []WebhookDelivery
+	err := db.Raw(`UPDATE "WebhookOutbox" SET "nextAttemptAt" = ? WHERE id IN (
+		SELECT id FROM "WebhookOutbox" WHERE "deliveredAt" IS NULL AND "nextAttemptAt" <= ?
+		ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED) RETURNING *`, now.Add(webhookClaimTimeout), now, webhookClaimSize).
+		Scan(&deliveries).Error
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries, err
+}
+
+// unclaimWebhookDeliveries makes claimed deliveries that were not attempted
+// due again right away.
+func unclaimWebhookDeliveries(db *gorm.DB, deliveries []WebhookDelivery) {
+	ids := make([]uint32, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		ids = append(ids, delivery.ID)
+	}
+	if err := db.Table("WebhookOutbox").Where("id IN ?", ids).Update("nextAttemptAt", time.Now()).Error; err != nil {
+		fmt.Println("Error releasing webhook outbox:", err)
+	}
+}
+
+// recordWebhookAttempt applies the outcome of one attempt and reports whether
+// it was delivered. Failed attempts back off exponentially from two minutes
+// until maxWebhookAttempts, then the delivery is given up.
+func recordWebhookAttempt(delivery *WebhookDelivery, err error, now time.Time) bool {
+	delivery.Attempts++
+	delivery.NextAttemptAt = nil
+	if err == nil {
+		delivery.DeliveredAt = &now
+		delivery.LastError = nil
+		return true
+	}
+	message := err.Error()
+	delivery.LastError = &message
+	if delivery.Attempts < maxWebhookAttempts {
+		nextAttemptAt := now.Add(time.Minute << delivery.Attempts)
+		delivery.NextAttemptAt = &nextAttemptAt
+	}
+	return false
+}
+
+func sendWebhook(delivery WebhookDelivery) error {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest("POST", delivery.Url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", fmt.Sprint(delivery.ID))
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Signature", signWebhookPayload(body))
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected HTTP status code: %d", res.StatusCode)
+	}
+	return nil
+}
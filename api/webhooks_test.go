@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSendWebhookSignsPayload(t *testing.T) {
+	t.Setenv("WEBHOOK_SECRET", "test-secret")
+	payload := `{"event":"release_date.changed","entity_type":"movie","entity_id":550}`
+
+	var gotBody []byte
+	var gotHeader http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeader = r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	delivery := WebhookDelivery{ID: 42, Url: server.URL, Event: "release_date.changed", Payload: payload}
+	if err := sendWebhook(delivery); err != nil {
+		t.Fatalf("sendWebhook: %v", err)
+	}
+
+	if string(gotBody) != payload {
+		t.Errorf("body = %s, want %s", gotBody, payload)
+	}
+	mac := hmac.New(sha256.New, []byte("test-secret"))
+	mac.Write([]byte(payload))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := gotHeader.Get("X-Webhook-Signature"); got != want {
+		t.Errorf("X-Webhook-Signature = %s, want %s", got, want)
+	}
+	if got := gotHeader.Get("X-Webhook-Id"); got != "42" {
+		t.Errorf("X-Webhook-Id = %s, want 42", got)
+	}
+	if got := gotHeader.Get("X-Webhook-Event"); got != "release_date.changed" {
+		t.Errorf("X-Webhook-Event = %s, want release_date.changed", got)
+	}
+	if got := gotHeader.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %s, want application/json", got)
+	}
+}
+
+func TestSendWebhookSignatureDependsOnSecret(t *testing.T) {
+	t.Setenv("WEBHOOK_SECRET", "one")
+	first := signWebhookPayload([]byte("{}"))
+	t.Setenv("WEBHOOK_SECRET", "two")
+	if second := signWebhookPayload([]byte("{}")); first == second {
+		t.Errorf("signature %s did not change with the secret", first)
+	}
+}
+
+func TestSendWebhookStatus(t *testing.T) {
+	tests := []struct {
+		status  int
+		wantErr bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusAccepted, false},
+		{http.StatusNotFound, true},
+		{http.StatusBadRequest, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+		}))
+		err := sendWebhook(WebhookDelivery{ID: 1, Url: server.URL, Payload: "{}"})
+		server.Close()
+		if (err != nil) != test.wantErr {
+			t.Errorf("status %d: err = %v, want error %v", test.status, err, test.wantErr)
+		}
+	}
+}
+
+func TestRecordWebhookAttemptBackoff(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		attempts     uint16
+		err          error
+		wantAttempts uint16
+		wantNext     time.Duration
+		wantGiveUp   bool
+	}{
+		{"first failure", 0, errors.New("boom"), 1, 2 * time.Minute, false},
+		{"second failure", 1, errors.New("boom"), 2, 4 * time.Minute, false},
+		{"fifth failure", 4, errors.New("boom"), 5, 32 * time.Minute, false},
+		{"ninth failure", 8, errors.New("boom"), 9, 512 * time.Minute, false},
+		{"last failure", maxWebhookAttempts - 1, errors.New("boom"), maxWebhookAttempts, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delivery := WebhookDelivery{Attempts: test.attempts}
+			if recordWebhookAttempt(&delivery, test.err, now) {
+				t.Fatal("failed attempt reported as delivered")
+			}
+			if delivery.Attempts != test.wantAttempts {
+				t.Errorf("Attempts = %d, want %d", delivery.Attempts, test.wantAttempts)
+			}
+			if delivery.DeliveredAt != nil {
+				t.Errorf("DeliveredAt = %v, want nil", delivery.DeliveredAt)
+			}
+			if delivery.LastError == nil || *delivery.LastError != "boom" {
+				t.Errorf("LastError = %v, want boom", delivery.LastError)
+			}
+			if test.wantGiveUp {
+				if delivery.NextAttemptAt != nil {
+					t.Errorf("NextAttemptAt = %v, want nil after the last attempt", delivery.NextAttemptAt)
+				}
+				return
+			}
+			if delivery.NextAttemptAt == nil || !delivery.NextAttemptAt.Equal(now.Add(test.wantNext)) {
+				t.Errorf("NextAttemptAt = %v, want %v", delivery.NextAttemptAt, now.Add(test.wantNext))
+			}
+		})
+	}
+}
+
+func TestWebhookRetryAfterFailure(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	delivery := WebhookDelivery{ID: 7, Url: server.URL, Event: "movie.created", Payload: "{}"}
+	first := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	if recordWebhookAttempt(&delivery, sendWebhook(delivery), first) {
+		t.Fatal("502 response reported as delivered")
+	}
+	if delivery.NextAttemptAt == nil || !delivery.NextAttemptAt.Equal(first.Add(2*time.Minute)) {
+		t.Fatalf("NextAttemptAt = %v, want %v", delivery.NextAttemptAt, first.Add(2*time.Minute))
+	}
+
+	second := *delivery.NextAttemptAt
+	if !recordWebhookAttempt(&delivery, sendWebhook(delivery), second) {
+		t.Fatalf("retry not delivered: %v", *delivery.LastError)
+	}
+	if delivery.Attempts != 2 {
+		t.Errorf("Attempts = %d, want 2", delivery.Attempts)
+	}
+	if delivery.DeliveredAt == nil || !delivery.DeliveredAt.Equal(second) {
+		t.Errorf("DeliveredAt = %v, want %v", delivery.DeliveredAt, second)
+	}
+	if delivery.NextAttemptAt != nil || delivery.LastError != nil {
+		t.Errorf("NextAttemptAt = %v, LastError = %v, want both cleared", delivery.NextAttemptAt, delivery.LastError)
+	}
+	if calls != 2 {
+		t.Errorf("endpoint called %d times, want 2", calls)
+	}
+}
@@ -3,6 +3,7 @@ module wiitco-db-games-cron
 go 1.21.5
 
 require (
+	github.com/graphql-go/graphql v0.8.1
 	gorm.io/driver/postgres v1.5.4
 	gorm.io/gorm v1.25.5
 )